@@ -4,6 +4,7 @@ import (
 	"github.com/MoWan-inc/aqua/cmd/server"
 	"github.com/MoWan-inc/aqua/cmd/util"
 	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/MoWan-inc/aqua/pkg/version"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +18,9 @@ var (
 func mainFunc() error {
 	rootCmd.PersistentFlags().StringVar(&config.LogConfigPath, "log-config-path", "", "log config file path, if empty use debug mode")
 
-	// TODO: 初始化日志相关配置
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return log.Init(config.LogConfigPath)
+	}
 
 	rootCmd.AddCommand(server.NewCmd())
 
@@ -26,5 +29,6 @@ func mainFunc() error {
 
 func main() {
 	err := mainFunc()
+	_ = log.Sync()
 	util.ExitPrintFatalError(err)
 }
@@ -2,13 +2,21 @@ package server
 
 import (
 	"context"
+	"errors"
 	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/dao"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/service/handler"
+	"github.com/MoWan-inc/aqua/pkg/service/job"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/samber/do"
 	"github.com/spf13/cobra"
+	"net/http"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-// TODO: 添加zlog及其配置，添加日志
 func NewCmd() *cobra.Command {
 	cfg := config.DefaultServerConfig()
 
@@ -19,6 +27,12 @@ func NewCmd() *cobra.Command {
 			if err := cfg.Validate(); err != nil {
 				return err
 			}
+			// 命令行未指定日志配置时，使用配置文件中的日志配置
+			if path := cfg.GetLogConfigPath(); path != config.LogConfigPath {
+				if err := log.Init(path); err != nil {
+					return err
+				}
+			}
 			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 			defer cancel()
 			return mainFunc(ctx, cfg)
@@ -29,5 +43,51 @@ func NewCmd() *cobra.Command {
 }
 
 func mainFunc(ctx context.Context, cfg *config.ServerConfig) error {
-	return nil
+	injector := do.New()
+	// 关闭时释放数据库连接等资源
+	defer func() { _ = injector.Shutdown() }()
+
+	do.ProvideValue(injector, cfg)
+	if cfg.RBAC != nil {
+		do.ProvideValue(injector, cfg.RBAC)
+	}
+	baseDAO := dao.NewDAO(*cfg.GetDatabase())
+	do.ProvideValue[aquadao.DAO](injector, baseDAO)
+	if cfg.Purge != nil {
+		go job.NewPurgeJob(baseDAO, cfg.Purge).Run(ctx)
+	}
+
+	engine, err := handler.NewServer(injector, cfg.Api)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:    cfg.Api.Addr,
+		Handler: engine,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		defer close(serveErr)
+		log.Infof("server listening on %s", cfg.Api.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// 优雅退出，等待处理中的请求完成
+	timeout := time.Duration(cfg.Api.GracefullyShutDownSeconds) * time.Second
+	log.Infof("server shutting down, waiting %v for in-flight requests", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	return <-serveErr
 }
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 聚合函数
+const (
+	AggCount         = "count"
+	AggCountDistinct = "count_distinct"
+	AggSum           = "sum"
+	AggAvg           = "avg"
+	AggMin           = "min"
+	AggMax           = "max"
+)
+
+// 时间分桶
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+// 分组列和聚合函数的数量上限，避免构造过大的SQL
+const (
+	maxGroupColumns = 8
+	maxMetrics      = 16
+)
+
+var aggFuncs = map[string]any{
+	AggCount: struct{}{}, AggCountDistinct: struct{}{}, AggSum: struct{}{},
+	AggAvg: struct{}{}, AggMin: struct{}{}, AggMax: struct{}{},
+}
+
+var buckets = map[string]any{
+	BucketHour: struct{}{}, BucketDay: struct{}{}, BucketWeek: struct{}{},
+	BucketMonth: struct{}{}, BucketYear: struct{}{},
+}
+
+/*
+AggregateRequest 聚合查询参数，过滤条件使用 QueryRequest
+group_by 分组列，逗号分隔，时间列可以用 :day 等分桶，例如 status,created_at:day
+metrics 聚合函数，逗号分隔，除count外需要指定列，例如 count,sum:amount,count_distinct:user_id，为空时为count
+*/
+type AggregateRequest struct {
+	GroupBy string `form:"group_by" json:"group_by"`
+	Metrics string `form:"metrics" json:"metrics"`
+}
+
+// GroupColumn 分组列，Bucket 不为空时按时间分桶
+type GroupColumn struct {
+	Field  string
+	Bucket string
+}
+
+// Alias 结果中的字段名
+func (g GroupColumn) Alias() string {
+	if len(g.Bucket) > 0 {
+		return g.Field + "_" + g.Bucket
+	}
+	return g.Field
+}
+
+// Metric 聚合函数，count 的 Field 可以为空
+type Metric struct {
+	Func  string
+	Field string
+}
+
+// Alias 结果中的字段名
+func (m Metric) Alias() string {
+	if len(m.Field) > 0 {
+		return m.Func + "_" + m.Field
+	}
+	return m.Func
+}
+
+type Aggregation struct {
+	GroupBy []GroupColumn
+	Metrics []Metric
+}
+
+// Parse 解析分组列和聚合函数
+func (r *AggregateRequest) Parse() *Aggregation {
+	agg := &Aggregation{}
+	for _, item := range splitItems(r.GroupBy) {
+		field, bucket, _ := strings.Cut(item, ":")
+		agg.GroupBy = append(agg.GroupBy, GroupColumn{Field: strings.TrimSpace(field), Bucket: strings.ToLower(strings.TrimSpace(bucket))})
+	}
+	for _, item := range splitItems(r.Metrics) {
+		fn, field, _ := strings.Cut(item, ":")
+		agg.Metrics = append(agg.Metrics, Metric{Func: strings.ToLower(strings.TrimSpace(fn)), Field: strings.TrimSpace(field)})
+	}
+	if len(agg.Metrics) == 0 {
+		agg.Metrics = []Metric{{Func: AggCount}}
+	}
+	return agg
+}
+
+// Validate 列必须是模型的gorm列，结果字段名不能重复
+func (a *Aggregation) Validate(fields map[string]any) error {
+	if len(a.GroupBy) > maxGroupColumns {
+		return fmt.Errorf("aggregate error, more than %d group by columns", maxGroupColumns)
+	}
+	if len(a.Metrics) == 0 || len(a.Metrics) > maxMetrics {
+		return fmt.Errorf("aggregate error, metrics count should be between 1 and %d", maxMetrics)
+	}
+	aliases := map[string]any{}
+	for _, g := range a.GroupBy {
+		if _, ok := fields[g.Field]; !ok {
+			return fmt.Errorf("aggregate error, invalid group by field %s", g.Field)
+		}
+		if _, ok := buckets[g.Bucket]; !ok && len(g.Bucket) > 0 {
+			return fmt.Errorf("aggregate error, invalid bucket %s of %s", g.Bucket, g.Field)
+		}
+		if _, ok := aliases[g.Alias()]; ok {
+			return fmt.Errorf("aggregate error, duplicated group by %s", g.Alias())
+		}
+		aliases[g.Alias()] = struct{}{}
+	}
+	for _, m := range a.Metrics {
+		if _, ok := aggFuncs[m.Func]; !ok {
+			return fmt.Errorf("aggregate error, unknown function %s", m.Func)
+		}
+		if len(m.Field) == 0 && m.Func != AggCount {
+			return fmt.Errorf("aggregate error, %s requires a field", m.Func)
+		}
+		if _, ok := fields[m.Field]; !ok && len(m.Field) > 0 {
+			return fmt.Errorf("aggregate error, invalid metric field %s", m.Field)
+		}
+		if _, ok := aliases[m.Alias()]; ok {
+			return fmt.Errorf("aggregate error, duplicated metric %s", m.Alias())
+		}
+		aliases[m.Alias()] = struct{}{}
+	}
+	return nil
+}
+
+func splitItems(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -12,6 +12,7 @@ type QueryRequest struct {
 	Pagination
 	Sorting
 	Filter
+	Fieldset
 	Not any `json:"not"`
 }
 
@@ -40,32 +41,47 @@ func DeleteFor(model domain.Indexer) *DeleteRequest {
 
 func (r *QueryRequest) Validate() error {
 	var fields map[string]any
-	if len(r.SortBy) > 0 || len(r.Fields) > 0 {
+	if len(r.Sort) > 0 || len(r.SortBy) > 0 || len(r.Fields) > 0 || len(r.Expr) > 0 || len(r.Select) > 0 {
 		fields = domain.GetGormFields(object.ClassName(r.Query))
 	}
+	if err := ValidateColumns(fields, r.Columns()); err != nil {
+		return err
+	}
 	// sorting
-	if len(r.SortBy) > 0 {
-		if _, ok := fields[strings.TrimSpace(r.SortBy)]; !ok {
-			return fmt.Errorf("query option error, invalid sort_by in fields: %s", r.SortBy)
+	keys, err := r.Sorting.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, ok := fields[key.Field]; !ok {
+			return fmt.Errorf("query option error, invalid sort field %s", key.Field)
 		}
 	}
 	// filter
 	if len(r.Fields) > 0 {
 		filters := strings.Split(r.Fields, ",")
 		for _, filter := range filters {
+			// 只接受模型的列名，带表名前缀的写法在DAO中也会被拒绝
 			filter = strings.TrimSpace(filter)
-			//
-			if strings.Contains(filter, ".") {
-				filterTks := strings.Split(filter, ".")
-				if len(filterTks) > 0 {
-					filter = filterTks[len(filterTks)-1]
-				}
-			}
 			if _, ok := fields[filter]; !ok {
 				return fmt.Errorf("query option error, invalid filter %s in fields: %v", filter, r.Fields)
 			}
 		}
 	}
+	// filter expression
+	if len(r.Expr) > 0 {
+		expr, err := ParseFilter(r.Expr)
+		if err != nil {
+			return err
+		}
+		if expr != nil {
+			for _, f := range expr.Fields() {
+				if _, ok := fields[f]; !ok {
+					return fmt.Errorf("query option error, invalid filter field %s", f)
+				}
+			}
+		}
+	}
 	return nil
 }
 
@@ -85,6 +101,16 @@ func (r *DeleteRequest) Validate() error {
 	return nil
 }
 
+// ValidateColumns 列必须是模型的gorm列
+func ValidateColumns(fields map[string]any, columns []string) error {
+	for _, c := range columns {
+		if _, ok := fields[c]; !ok {
+			return fmt.Errorf("query option error, invalid select field %s", c)
+		}
+	}
+	return nil
+}
+
 type Validator interface {
 	Validate() error
 }
@@ -1,10 +1,16 @@
 package api
 
+import "strings"
+
 type Pagination struct {
 	// 当前页码, 从1开始
 	Page int `form:"page" json:"page" validate:"gte=1"`
 	// 每页数量
 	PageSize int `form:"page_size" json:"page_size" validate:"gte=1,lte=100"`
+	// 游标分页，为上一页返回的 next_cursor，使用时忽略页码
+	Cursor string `form:"cursor" json:"cursor,omitempty"`
+	// 查询结果填充，满页时为下一页的游标
+	NextCursor string `form:"-" json:"-"`
 }
 
 func (p *Pagination) Empty() bool {
@@ -12,6 +18,9 @@ func (p *Pagination) Empty() bool {
 }
 
 type Sorting struct {
+	// 多列排序，语法见 ParseSort，例如 -priority,created_at,name
+	Sort string `form:"sort" json:"sort"`
+	// 单列排序，Sort 为空时使用
 	SortBy   string `form:"sort_by" json:"sort_by"`
 	SortDesc bool   `form:"sort_desc" json:"sort_desc"`
 }
@@ -19,6 +28,25 @@ type Sorting struct {
 type Filter struct {
 	Filters string `form:"filters" json:"filters"`
 	Fields  string `form:"fields" json:"fields"`
+	// 过滤表达式，语法见 FilterExpr，例如 status eq 'active' and created_at gt '2026-01-01'
+	Expr string `form:"filter" json:"filter"`
+}
+
+// Fieldset 只查询和返回指定的列，逗号分隔，例如 id,name，为空时返回全部
+type Fieldset struct {
+	Select string `form:"select" json:"select"`
+}
+
+// Columns 指定的列
+func (f *Fieldset) Columns() []string {
+	if len(strings.TrimSpace(f.Select)) == 0 {
+		return nil
+	}
+	columns := make([]string, 0)
+	for _, c := range strings.Split(f.Select, ",") {
+		columns = append(columns, strings.TrimSpace(c))
+	}
+	return columns
 }
 
 // PaginationSortingFilter 分页、排序、过滤, 用于查询请求的过滤条件
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// InvalidCursorError 游标被篡改、格式错误或排序已变化
+var InvalidCursorError = errors.New("invalid cursor")
+
+var (
+	cursorMu     sync.RWMutex
+	cursorSecret = randomCursorSecret()
+)
+
+// 未配置密钥时使用随机密钥，游标只在当前进程内有效
+func randomCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("generate cursor secret error: %v", err))
+	}
+	return secret
+}
+
+// SetCursorSecret 设置游标签名密钥，多副本部署时需要配置相同的密钥
+func SetCursorSecret(secret string) {
+	if len(secret) == 0 {
+		return
+	}
+	cursorMu.Lock()
+	defer cursorMu.Unlock()
+	cursorSecret = []byte(secret)
+}
+
+// cursorPayload 游标内容，Sort 为生成游标时的排序，排序变化后游标失效
+type cursorPayload struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// EncodeCursor 将最后一行的排序列值编码为签名的游标
+func EncodeCursor(keys []SortKey, values []any) (string, error) {
+	raws := make([]json.RawMessage, 0, len(values))
+	for _, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("cursor error, encode value %v: %w", v, err)
+		}
+		raws = append(raws, raw)
+	}
+	payload, err := json.Marshal(cursorPayload{Sort: FormatSort(keys), Values: raws})
+	if err != nil {
+		return "", fmt.Errorf("cursor error: %w", err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(cursorSign(body)), nil
+}
+
+// DecodeCursor 校验签名和排序，返回每个排序列的json值
+func DecodeCursor(cursor string, keys []SortKey) ([]json.RawMessage, error) {
+	body, sign, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, fmt.Errorf("cursor error, malformed cursor: %w", InvalidCursorError)
+	}
+	expect, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil || !hmac.Equal(expect, cursorSign(body)) {
+		return nil, fmt.Errorf("cursor error, invalid signature: %w", InvalidCursorError)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("cursor error, malformed cursor: %w", InvalidCursorError)
+	}
+	payload := cursorPayload{}
+	if err = json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("cursor error, malformed cursor: %w", InvalidCursorError)
+	}
+	if payload.Sort != FormatSort(keys) || len(payload.Values) != len(keys) {
+		return nil, fmt.Errorf("cursor error, sorting changed: %w", InvalidCursorError)
+	}
+	return payload.Values, nil
+}
+
+func cursorSign(body string) []byte {
+	cursorMu.RLock()
+	defer cursorMu.RUnlock()
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
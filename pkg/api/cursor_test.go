@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// setTestCursorSecret 测试结束后恢复原来的密钥
+func setTestCursorSecret(t *testing.T, secret string) {
+	t.Helper()
+	cursorMu.RLock()
+	old := cursorSecret
+	cursorMu.RUnlock()
+	SetCursorSecret(secret)
+	t.Cleanup(func() {
+		cursorMu.Lock()
+		defer cursorMu.Unlock()
+		cursorSecret = old
+	})
+}
+
+func mustParseSort(t *testing.T, s string) []SortKey {
+	t.Helper()
+	keys, err := ParseSort(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	setTestCursorSecret(t, "secret")
+	keys := mustParseSort(t, "-priority:nulls_last,name,id")
+	cursor, err := EncodeCursor(keys, []any{nil, "it's", 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := DecodeCursor(cursor, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"null", `"it's"`, "3"}
+	for i, v := range values {
+		if string(v) != want[i] {
+			t.Fatalf("value %d = %s, want %s", i, v, want[i])
+		}
+	}
+}
+
+// TestInvalidCursor 篡改、格式错误、排序变化或密钥不同的游标都返回 InvalidCursorError
+func TestInvalidCursor(t *testing.T) {
+	setTestCursorSecret(t, "secret")
+	keys := mustParseSort(t, "name")
+	cursor, err := EncodeCursor(keys, []any{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, sign, _ := strings.Cut(cursor, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"name","v":["z"]}`))
+	flip := func(s string) string {
+		b := []byte(s)
+		if b[0] == 'A' {
+			b[0] = 'B'
+		} else {
+			b[0] = 'A'
+		}
+		return string(b)
+	}
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+		secret string
+	}{
+		{"tampered signature", body + "." + flip(sign), "name", ""},
+		{"tampered body", forged + "." + sign, "name", ""},
+		{"missing signature", body, "name", ""},
+		{"empty signature", body + ".", "name", ""},
+		{"invalid signature encoding", body + ".!!", "name", ""},
+		{"garbage", "not a cursor", "name", ""},
+		{"different sort_by", cursor, "id", ""},
+		{"different direction", cursor, "-name", ""},
+		{"different nulls", cursor, "name:nulls_last", ""},
+		{"more sort keys", cursor, "name,id", ""},
+		{"wrong secret", cursor, "name", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.secret) > 0 {
+				setTestCursorSecret(t, tt.secret)
+			}
+			if values, err := DecodeCursor(tt.cursor, mustParseSort(t, tt.sort)); !errors.Is(err, InvalidCursorError) {
+				t.Fatalf("decode cursor = %s, %v, want %v", values, err, InvalidCursorError)
+			}
+		})
+	}
+	// 使用相同密钥的其他副本可以解析
+	setTestCursorSecret(t, "secret")
+	if _, err = DecodeCursor(cursor, keys); err != nil {
+		t.Fatalf("decode cursor with same secret error = %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 过滤表达式支持的比较操作
+const (
+	FilterEq      = "eq"
+	FilterNe      = "ne"
+	FilterGt      = "gt"
+	FilterGe      = "ge"
+	FilterLt      = "lt"
+	FilterLe      = "le"
+	FilterLike    = "like"
+	FilterIn      = "in"
+	FilterBetween = "between"
+	FilterIsNull  = "is null"
+	FilterNotNull = "is not null"
+
+	FilterAnd = "and"
+	FilterOr  = "or"
+)
+
+// 过滤表达式最大长度和嵌套深度，避免构造过大的SQL
+const (
+	maxFilterLength = 4096
+	maxFilterDepth  = 16
+)
+
+var filterCompareOps = map[string]any{
+	FilterEq: struct{}{}, FilterNe: struct{}{}, FilterGt: struct{}{}, FilterGe: struct{}{},
+	FilterLt: struct{}{}, FilterLe: struct{}{}, FilterLike: struct{}{},
+}
+
+/*
+FilterExpr 过滤表达式语法树，由 ParseFilter 解析得到
+语法示例：status eq 'active' and (created_at gt '2026-01-01' or not name like '%test%')
+
+	expr       = or
+	or         = and { "or" and }
+	and        = unary { "and" unary }
+	unary      = "not" unary | "(" expr ")" | condition
+	condition  = field ( op value | "in" "(" value { "," value } ")" | "between" value "and" value | "is" [ "not" ] "null" )
+	op         = eq | ne | gt | ge | lt | le | like
+	value      = 'string' | "string" | number | true | false
+*/
+type FilterExpr interface {
+	// Fields 表达式中引用的所有字段，用于校验
+	Fields() []string
+}
+
+// FilterLogic and/or 组合
+type FilterLogic struct {
+	Op    string
+	Exprs []FilterExpr
+}
+
+func (e *FilterLogic) Fields() []string {
+	var fields []string
+	for _, sub := range e.Exprs {
+		fields = append(fields, sub.Fields()...)
+	}
+	return fields
+}
+
+type FilterNot struct {
+	Expr FilterExpr
+}
+
+func (e *FilterNot) Fields() []string {
+	return e.Expr.Fields()
+}
+
+// FilterCondition 单个字段的比较条件
+type FilterCondition struct {
+	Field  string
+	Op     string
+	Values []any
+}
+
+func (e *FilterCondition) Fields() []string {
+	return []string{e.Field}
+}
+
+// ParseFilter 解析过滤表达式，空字符串返回nil
+func ParseFilter(s string) (FilterExpr, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, nil
+	}
+	if len(s) > maxFilterLength {
+		return nil, fmt.Errorf("filter error, expression longer than %d", maxFilterLength)
+	}
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("filter error, unexpected %q", p.peek().text)
+	}
+	return expr, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterIdent filterTokenKind = iota
+	filterString
+	filterNumber
+	filterLParen
+	filterRParen
+	filterComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: filterLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: filterRParen, text: ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, filterToken{kind: filterComma, text: ","})
+			i++
+		case r == '\'' || r == '"':
+			// 引号内连续两个引号表示引号本身
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(runes) {
+				if runes[j] == r {
+					if j+1 < len(runes) && runes[j+1] == r {
+						b.WriteRune(r)
+						j += 2
+						continue
+					}
+					closed = true
+					break
+				}
+				b.WriteRune(runes[j])
+				j++
+			}
+			if !closed {
+				return nil, fmt.Errorf("filter error, unterminated string at %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: b.String()})
+			i = j + 1
+		case r == '-' || r == '+' || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: string(runes[i:j])})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i + 1
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("filter error, unexpected character %q at %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	if p.eof() {
+		return filterToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.eof() {
+		return filterToken{}, fmt.Errorf("filter error, unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+// keyword 当前token是指定关键字时消费并返回true
+func (p *filterParser) keyword(kw string) bool {
+	t := p.peek()
+	if !p.eof() && t.kind == filterIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(kind filterTokenKind, text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != kind {
+		return fmt.Errorf("filter error, expect %q but got %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr(depth int) (FilterExpr, error) {
+	return p.parseLogic(depth, FilterOr, p.parseAnd)
+}
+
+func (p *filterParser) parseAnd(depth int) (FilterExpr, error) {
+	return p.parseLogic(depth, FilterAnd, p.parseUnary)
+}
+
+func (p *filterParser) parseLogic(depth int, op string, operand func(int) (FilterExpr, error)) (FilterExpr, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return nil, err
+	}
+	exprs := []FilterExpr{first}
+	for p.keyword(op) {
+		e, err := operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return &FilterLogic{Op: op, Exprs: exprs}, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (FilterExpr, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("filter error, nested deeper than %d", maxFilterDepth)
+	}
+	if p.keyword("not") {
+		e, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &FilterNot{Expr: e}, nil
+	}
+	if !p.eof() && p.peek().kind == filterLParen {
+		p.pos++
+		e, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(filterRParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (FilterExpr, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.kind != filterIdent {
+		return nil, fmt.Errorf("filter error, expect field but got %q", field.text)
+	}
+	opToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if opToken.kind != filterIdent {
+		return nil, fmt.Errorf("filter error, expect operator after %s but got %q", field.text, opToken.text)
+	}
+	cond := &FilterCondition{Field: field.text, Op: strings.ToLower(opToken.text)}
+	switch {
+	case cond.Op == FilterIn:
+		if err = p.expect(filterLParen, "("); err != nil {
+			return nil, err
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			cond.Values = append(cond.Values, v)
+			if !p.eof() && p.peek().kind == filterComma {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if err = p.expect(filterRParen, ")"); err != nil {
+			return nil, err
+		}
+	case cond.Op == FilterBetween:
+		low, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(FilterAnd) {
+			return nil, fmt.Errorf("filter error, expect and in between of %s", field.text)
+		}
+		high, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cond.Values = []any{low, high}
+	case cond.Op == "is":
+		cond.Op = FilterIsNull
+		if p.keyword("not") {
+			cond.Op = FilterNotNull
+		}
+		if !p.keyword("null") {
+			return nil, fmt.Errorf("filter error, expect null after is of %s", field.text)
+		}
+	default:
+		if _, ok := filterCompareOps[cond.Op]; !ok {
+			return nil, fmt.Errorf("filter error, unknown operator %s", opToken.text)
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cond.Values = []any{v}
+	}
+	return cond, nil
+}
+
+func (p *filterParser) parseValue() (any, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case filterString:
+		return t.text, nil
+	case filterNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter error, invalid number %s", t.text)
+		}
+		return f, nil
+	case filterIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("filter error, expect value but got %q", t.text)
+}
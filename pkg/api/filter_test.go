@@ -0,0 +1,164 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+// formatFilter 以前缀形式输出语法树，便于比较优先级和结合方式
+func formatFilter(expr FilterExpr) string {
+	switch e := expr.(type) {
+	case nil:
+		return "<nil>"
+	case *FilterLogic:
+		parts := make([]string, 0, len(e.Exprs))
+		for _, sub := range e.Exprs {
+			parts = append(parts, formatFilter(sub))
+		}
+		return "(" + e.Op + " " + strings.Join(parts, " ") + ")"
+	case *FilterNot:
+		return "(not " + formatFilter(e.Expr) + ")"
+	case *FilterCondition:
+		values := make([]string, 0, len(e.Values))
+		for _, v := range e.Values {
+			values = append(values, fmt.Sprintf("%#v", v))
+		}
+		if len(values) == 0 {
+			return "[" + e.Field + " " + e.Op + "]"
+		}
+		return "[" + e.Field + " " + e.Op + " " + strings.Join(values, ",") + "]"
+	}
+	return fmt.Sprintf("unknown %T", expr)
+}
+
+// nested 嵌套 n 层括号
+func nested(n int) string {
+	return strings.Repeat("(", n) + "a eq 1" + strings.Repeat(")", n)
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"empty", "", "<nil>"},
+		{"blank", "  \t ", "<nil>"},
+		{"and over or", "a eq 1 or b eq 2 and c eq 3", "(or [a eq 1] (and [b eq 2] [c eq 3]))"},
+		{"and before or", "a eq 1 and b eq 2 or c eq 3", "(or (and [a eq 1] [b eq 2]) [c eq 3])"},
+		{"flat and", "a eq 1 and b eq 2 and c eq 3", "(and [a eq 1] [b eq 2] [c eq 3])"},
+		{"not over and", "not a eq 1 and b eq 2", "(and (not [a eq 1]) [b eq 2])"},
+		{"double not", "not not a eq 1", "(not (not [a eq 1]))"},
+		{"parentheses", "(a eq 1 or b eq 2) and c eq 3", "(and (or [a eq 1] [b eq 2]) [c eq 3])"},
+		{"not parentheses", "not (a eq 1 or b eq 2)", "(not (or [a eq 1] [b eq 2]))"},
+		{"redundant parentheses", "((a eq 1))", "[a eq 1]"},
+		{"case insensitive", "A EQ 1 AND b Like 'x%' Or NOT c Ne 2", `(or (and [A eq 1] [b like "x%"]) (not [c ne 2]))`},
+		{"compare ops", "a gt 1 and a ge 1 and a lt 1 and a le 1", "(and [a gt 1] [a ge 1] [a lt 1] [a le 1])"},
+		{"in", "a in (1, 'x', true)", `[a in 1,"x",true]`},
+		{"in single", "a in ('x')", `[a in "x"]`},
+		{"between", "a between 1 and 10 and b eq 2", "(and [a between 1,10] [b eq 2])"},
+		{"between strings", "a between '2026-01-01' and '2026-02-01'", `[a between "2026-01-01","2026-02-01"]`},
+		{"is null", "a is null", "[a is null]"},
+		{"is not null", "a IS NOT NULL or b is null", "(or [a is not null] [b is null])"},
+		{"not is null", "not a is null", "(not [a is null])"},
+		{"single quote escape", "a eq 'it''s'", `[a eq "it's"]`},
+		{"double quote escape", `a eq "say ""hi"""`, `[a eq "say \"hi\""]`},
+		{"other quote", `a eq 'x"y' and b eq "x'y"`, `(and [a eq "x\"y"] [b eq "x'y"])`},
+		{"empty string", "a eq ''", `[a eq ""]`},
+		{"keyword in string", "a eq 'b or c) and ('", `[a eq "b or c) and ("]`},
+		{"numbers", "a eq -3 or a eq 1.5 or a eq +2", "(or [a eq -3] [a eq 1.5] [a eq 2])"},
+		{"booleans", "a eq true and b ne FALSE", "(and [a eq true] [b ne false])"},
+		{"unicode string", "name eq '名称'", `[name eq "名称"]`},
+		{"max depth", nested(maxFilterDepth), "[a eq 1]"},
+		{"max not depth", strings.Repeat("not ", maxFilterDepth) + "a eq 1", strings.Repeat("(not ", maxFilterDepth) + "[a eq 1]" + strings.Repeat(")", maxFilterDepth)},
+		{"max length", "a eq '" + strings.Repeat("x", maxFilterLength-len("a eq ''")) + "'", `[a eq "` + strings.Repeat("x", maxFilterLength-len("a eq ''")) + `"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) error = %v", tt.expr, err)
+			}
+			if got := formatFilter(expr); got != tt.want {
+				t.Fatalf("ParseFilter(%q) = %s, want %s", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseFilterError 非法的表达式返回错误，不会 panic
+func TestParseFilterError(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"unclosed paren", "(a eq 1"},
+		{"unopened paren", "a eq 1)"},
+		{"unbalanced nested", "((a eq 1)"},
+		{"empty parentheses", "()"},
+		{"trailing and", "a eq 1 and"},
+		{"trailing or", "a eq 1 or"},
+		{"trailing not", "a eq 1 and not"},
+		{"leading and", "and a eq 1"},
+		{"only not", "not"},
+		{"missing value", "a eq"},
+		{"missing operator", "a"},
+		{"missing connective", "a eq 1 b eq 2"},
+		{"unknown operator", "a foo 1"},
+		{"symbol operator", "a = 1"},
+		{"field value", "a eq b"},
+		{"string field", "'a' eq 1"},
+		{"number field", "1 eq 1"},
+		{"unterminated string", "a eq 'x"},
+		{"escaped end quote", "a eq 'x''"},
+		{"invalid number", "a eq 1.2.3"},
+		{"sign only", "a eq -"},
+		{"unexpected character", "a eq 1; drop table x"},
+		{"in without parentheses", "a in 1"},
+		{"in empty", "a in ()"},
+		{"in trailing comma", "a in (1,)"},
+		{"in unclosed", "a in (1, 2"},
+		{"between missing and", "a between 1 or 2"},
+		{"between missing high", "a between 1 and"},
+		{"is missing null", "a is"},
+		{"is not missing null", "a is not"},
+		{"is value", "a is 1"},
+		{"comma", ","},
+		{"too deep", nested(maxFilterDepth + 1)},
+		{"too deep not", strings.Repeat("not ", maxFilterDepth+1) + "a eq 1"},
+		{"too long", "a eq '" + strings.Repeat("x", maxFilterLength) + "'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expr, err := ParseFilter(tt.expr); err == nil {
+				t.Fatalf("ParseFilter(%q) = %s, want error", tt.expr, formatFilter(expr))
+			}
+		})
+	}
+}
+
+// TestFilterFields 表达式中的字段必须是模型的列
+func TestFilterFields(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"name eq 'a'", true},
+		{"name eq 'a' or (not permissions is null)", true},
+		{"unknown eq 1", false},
+		{"name eq 'a' or (not unknown is null)", false},
+		{"name in ('a') and x.name eq 'b'", false},
+		{"Name eq 'a'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			q := &QueryRequest{Query: &domain.Role{}, Filter: Filter{Expr: tt.expr}}
+			if err := q.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("validate %q error = %v, want valid %v", tt.expr, err, tt.valid)
+			}
+		})
+	}
+}
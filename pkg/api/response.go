@@ -13,6 +13,8 @@ type BaseResponse[T any] struct {
 type internalList[T any] struct {
 	Total int `json:"total"`
 	List  []T `json:"list"`
+	// 下一页游标，没有更多数据时为空
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type BaseListResponse[T any] struct {
@@ -51,6 +53,7 @@ func (rsp *BaseListResponse[T]) Merge(other ListResponse) error {
 	rsp.Msg = o.Msg
 	rsp.Data.List = append(rsp.Data.List, o.Data.List...)
 	rsp.Data.Total += o.Data.Total
+	rsp.Data.NextCursor = o.Data.NextCursor
 	return nil
 }
 
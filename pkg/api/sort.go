@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 排序时空值的位置
+const (
+	NullsFirst = "nulls_first"
+	NullsLast  = "nulls_last"
+)
+
+// 最多排序列数，避免构造过大的SQL
+const maxSortKeys = 8
+
+// SortKey 单列排序
+type SortKey struct {
+	Field string
+	Desc  bool
+	// 空值位置，为空时使用数据库默认行为
+	Nulls string
+}
+
+/*
+ParseSort 解析多列排序，逗号分隔，- 前缀表示降序，可选 :nulls_first / :nulls_last 指定空值位置
+例如：-priority:nulls_last,created_at,name
+*/
+func ParseSort(s string) ([]SortKey, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, nil
+	}
+	items := strings.Split(s, ",")
+	if len(items) > maxSortKeys {
+		return nil, fmt.Errorf("sort error, more than %d columns", maxSortKeys)
+	}
+	keys := make([]SortKey, 0, len(items))
+	seen := map[string]any{}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		key := SortKey{}
+		if field, nulls, ok := strings.Cut(item, ":"); ok {
+			switch strings.ToLower(strings.TrimSpace(nulls)) {
+			case NullsFirst:
+				key.Nulls = NullsFirst
+			case NullsLast:
+				key.Nulls = NullsLast
+			default:
+				return nil, fmt.Errorf("sort error, invalid nulls option %q", nulls)
+			}
+			item = strings.TrimSpace(field)
+		}
+		switch {
+		case strings.HasPrefix(item, "-"):
+			key.Desc = true
+			item = item[1:]
+		case strings.HasPrefix(item, "+"):
+			item = item[1:]
+		}
+		key.Field = strings.TrimSpace(item)
+		if len(key.Field) == 0 {
+			return nil, fmt.Errorf("sort error, empty column in %q", s)
+		}
+		if _, ok := seen[key.Field]; ok {
+			return nil, fmt.Errorf("sort error, duplicated column %s", key.Field)
+		}
+		seen[key.Field] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Keys 排序列，优先使用 Sort，兼容 SortBy/SortDesc
+func (s *Sorting) Keys() ([]SortKey, error) {
+	if len(s.Sort) > 0 {
+		return ParseSort(s.Sort)
+	}
+	if len(s.SortBy) > 0 {
+		return []SortKey{{Field: strings.TrimSpace(s.SortBy), Desc: s.SortDesc}}, nil
+	}
+	return nil, nil
+}
+
+// FormatSort 将排序列格式化为 ParseSort 的语法
+func FormatSort(keys []SortKey) string {
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		item := key.Field
+		if key.Desc {
+			item = "-" + item
+		}
+		if len(key.Nulls) > 0 {
+			item += ":" + key.Nulls
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
@@ -2,11 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 )
 
 type ApiConfig struct {
-	Addr        string `json:"addr" validate:"required,url里的版本prefix"`
+	Addr        string `json:"addr" validate:"required,hostname_port"`
 	EnablePProf bool   `json:"enable_pprof,omitempty"`
 	// 优雅退出
 	GracefullyShutDownSeconds int `json:"gracefully_shutdown_seconds,omitempty"`
@@ -16,6 +18,137 @@ type ApiConfig struct {
 	EnableSwagger bool `json:"enable_swagger,omitempty"`
 	// tokens，配置里允许的内部token
 	Tokens []string `json:"tokens,omitempty"`
+	// token签名校验配置
+	Sign *SignConfig `json:"sign,omitempty"`
+	// JWT bearer认证，为空时不启用
+	JWT *JWTConfig `json:"jwt,omitempty"`
+	// 限流，为空时不启用
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+	// 分页游标签名密钥，为空时使用随机密钥，多副本部署时需要配置
+	CursorSecret string `json:"cursor_secret,omitempty"`
+}
+
+const (
+	RateLimitByToken = "token"
+	RateLimitByIP    = "ip"
+	RateLimitByRoute = "route"
+)
+
+// RatePolicy 令牌桶限流策略，Rate 为每秒请求数，小于等于0表示不限流
+type RatePolicy struct {
+	// 限流维度：token 按认证用户（未认证时按ip），ip 按客户端ip，route 整个路由共享
+	By    string  `json:"by" validate:"oneof=token ip route"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst" validate:"gte=0"`
+}
+
+// Validate 限流时令牌桶至少能容纳一个请求，否则所有请求都会被拒绝
+func (p RatePolicy) Validate() error {
+	if p.Rate > 0 && p.Burst < 1 {
+		return fmt.Errorf("rate policy error, burst should be at least 1 when rate is %v, got %d", p.Rate, p.Burst)
+	}
+	return nil
+}
+
+// RateLimitConfig 限流策略优先级：token > 路由 > 默认
+type RateLimitConfig struct {
+	Default RatePolicy `json:"default"`
+	// key 为 "方法 路由"，例如 "GET /api/v1/template"
+	Routes map[string]RatePolicy `json:"routes,omitempty" validate:"dive"`
+	// key 为token或JWT subject
+	Tokens map[string]RatePolicy `json:"tokens,omitempty" validate:"dive"`
+	// 超过该时间没有请求的限流记录会被清理
+	IdleSeconds int `json:"idle_seconds,omitempty" validate:"gte=0"`
+	// 多副本共享限流额度，为空时进程内限流
+	Store *RateLimitStoreConfig `json:"store,omitempty"`
+}
+
+// RateLimitStoreConfig 兼容Redis协议的共享存储
+type RateLimitStoreConfig struct {
+	Addr     string `json:"addr" validate:"required,hostname_port"`
+	Password string `json:"password,omitempty"`
+	DB       int    `json:"db,omitempty"`
+	// key前缀，多个服务共用存储时区分
+	Prefix string `json:"prefix,omitempty"`
+	// 单次请求超时，超时后使用进程内限流
+	TimeoutMillis int `json:"timeout_millis,omitempty" validate:"gte=0"`
+}
+
+func (c *RateLimitConfig) Validate() error {
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("default %w", err)
+	}
+	for route, p := range c.Routes {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("route %s %w", route, err)
+		}
+	}
+	// 错误中不包含token
+	for _, p := range c.Tokens {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("token %w", err)
+		}
+	}
+	return nil
+}
+
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Default:     RatePolicy{By: RateLimitByToken, Rate: 1, Burst: 5},
+		IdleSeconds: 600,
+	}
+}
+
+// JWTConfig JWT认证配置，支持 HS256 和 RS256，密钥至少配置一种
+type JWTConfig struct {
+	// HS256 密钥
+	HMACSecret string `json:"hmac_secret,omitempty"`
+	// RS256 PEM格式公钥文件
+	RSAPublicKeyPath string `json:"rsa_public_key_path,omitempty"`
+	// 本地JWKS文件，按 kid 选择密钥，支持 RSA 和 oct 类型
+	JWKSPath string `json:"jwks_path,omitempty"`
+	// 非空时校验 iss、aud
+	Issuer   string `json:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty"`
+	// 允许的时钟偏差
+	LeewaySeconds int `json:"leeway_seconds,omitempty" validate:"gte=0"`
+	// 用户名和角色对应的claim
+	NameClaim  string `json:"name_claim,omitempty"`
+	RolesClaim string `json:"roles_claim,omitempty"`
+}
+
+/*
+SignConfig token签名配置，签名方式见 serviceutil.SignVerifier
+防重放的nonce只缓存在进程内，多副本部署时同一签名在不同副本上仍可以重放一次，
+需要更强的保证时缩短 ClockSkewSeconds 或在网关层做防重放
+*/
+type SignConfig struct {
+	// token对应的HMAC密钥，未配置密钥的token无法使用新版签名
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// 允许的客户端时钟偏差，超出则拒绝，同时决定nonce的缓存时间
+	ClockSkewSeconds int `json:"clock_skew_seconds,omitempty" validate:"gt=0"`
+	// 兼容旧版md5签名，默认开启避免已有客户端升级前被拒绝，所有token配置密钥并迁移后关闭
+	// 开启时每次接受旧版签名都记录警告日志
+	AllowLegacy bool `json:"allow_legacy"`
+}
+
+func DefaultSignConfig() *SignConfig {
+	return &SignConfig{
+		Secrets:          map[string]string{},
+		ClockSkewSeconds: 300,
+		AllowLegacy:      true,
+	}
+}
+
+// Validate 关闭旧版签名时至少需要一个密钥，否则所有token请求都会被拒绝
+func (c *SignConfig) Validate() error {
+	if c.ClockSkewSeconds <= 0 {
+		return fmt.Errorf("sign config error, clock skew seconds should be positive, got %d", c.ClockSkewSeconds)
+	}
+	if !c.AllowLegacy && len(c.Secrets) == 0 {
+		return errors.New("sign config error, secrets are required when legacy sign is not allowed")
+	}
+	return nil
 }
 
 func DefaultApiConfig() *ApiConfig {
@@ -24,11 +157,23 @@ func DefaultApiConfig() *ApiConfig {
 		GracefullyShutDownSeconds: 10,
 		Prefix:                    "v1",
 		Tokens:                    []string{""},
+		Sign:                      DefaultSignConfig(),
 	}
 }
 
 func (c *ApiConfig) Validate() error {
-	return validator.New().Struct(c)
+	if err := validator.New().Struct(c); err != nil {
+		return err
+	}
+	if c.RateLimit != nil {
+		if err := c.RateLimit.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Sign != nil {
+		return c.Sign.Validate()
+	}
+	return nil
 }
 
 func (c *ApiConfig) String() string {
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRateLimitValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(c *RateLimitConfig)
+		valid bool
+	}{
+		{"default", func(c *RateLimitConfig) {}, true},
+		{"unlimited without burst", func(c *RateLimitConfig) { c.Default = RatePolicy{By: RateLimitByIP} }, true},
+		{"default without burst", func(c *RateLimitConfig) { c.Default.Burst = 0 }, false},
+		{"route without burst", func(c *RateLimitConfig) {
+			c.Routes = map[string]RatePolicy{"GET /api/v1/template": {By: RateLimitByRoute, Rate: 10}}
+		}, false},
+		{"token without burst", func(c *RateLimitConfig) {
+			c.Tokens = map[string]RatePolicy{"tk": {By: RateLimitByToken, Rate: 0.5}}
+		}, false},
+		{"route with burst", func(c *RateLimitConfig) {
+			c.Routes = map[string]RatePolicy{"GET /api/v1/template": {By: RateLimitByRoute, Rate: 10, Burst: 1}}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultApiConfig()
+			cfg.RateLimit = DefaultRateLimitConfig()
+			tt.set(cfg.RateLimit)
+			err := cfg.Validate()
+			if (err == nil) != tt.valid {
+				t.Fatalf("validate error = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && strings.Contains(err.Error(), "tk") {
+				t.Fatalf("validate error should not contain token: %v", err)
+			}
+		})
+	}
+}
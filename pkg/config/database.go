@@ -0,0 +1,247 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DriverMysql    = "mysql"
+	DriverSqlite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+const (
+	DefaultReplicaCheckIntervalSeconds = 10
+	DefaultReplicaMaxLagSeconds        = 30
+)
+
+var defaultPorts = map[string]int{
+	DriverMysql:    3306,
+	DriverPostgres: 5432,
+}
+
+type ConnectionOption struct {
+	MaxIdleConns    int           `json:"max_idle_conns,omitempty"`
+	MaxOpenConns    int           `json:"max_open_conns,omitempty"`
+	ConnMaxLifeTime time.Duration `json:"conn_max_life_time,omitempty"`
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time,omitempty"`
+}
+
+// DatabaseConfig 数据库连接配置，DSN 为空时按驱动由 Host、Database 等字段生成
+type DatabaseConfig struct {
+	// Driver mysql、sqlite、postgres，为空时为 mysql
+	Driver string `json:"driver,omitempty"`
+	DSN    string `json:"dsn,omitempty"`
+	Host   string `json:"host,omitempty"`
+	// 为0时使用驱动的默认端口
+	Port     int    `json:"port,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	// sqlite 时为数据库文件路径，:memory: 为内存数据库
+	Database string `json:"database,omitempty"`
+	// 驱动参数，例如 mysql 的 charset、postgres 的 sslmode、sqlite 的 _pragma
+	Params     map[string]string `json:"params,omitempty"`
+	ConnOption *ConnectionOption `json:"conn_option"`
+	// Replicas 只读副本的 DSN，读操作路由到副本，驱动和连接池配置与主库相同
+	Replicas []string `json:"replicas,omitempty"`
+	// 副本健康检查间隔，为0时使用默认值
+	ReplicaCheckIntervalSeconds int `json:"replica_check_interval_seconds,omitempty"`
+	// 副本允许的最大复制延迟，超过时不再读取该副本，为0时使用默认值
+	ReplicaMaxLagSeconds int `json:"replica_max_lag_seconds,omitempty"`
+}
+
+// MysqlConfig 兼容旧配置，Driver 为空即为 mysql
+type MysqlConfig = DatabaseConfig
+
+func DefaultDatabaseConfig() *DatabaseConfig {
+	return &DatabaseConfig{}
+}
+
+func DefaultMysqlConfig() *MysqlConfig {
+	return DefaultDatabaseConfig()
+}
+
+func (c *DatabaseConfig) Set(s string) error {
+	content, err := getConfigContent(s)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(content, c)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *DatabaseConfig) String() string {
+	content, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return string(content)
+}
+
+// Type mysql 驱动保持旧的 MysqlConfig，兼容依赖该值的命令行参数和工具
+func (c *DatabaseConfig) Type() string {
+	if c.GetDriver() == DriverMysql {
+		return "MysqlConfig"
+	}
+	return "DatabaseConfig"
+}
+
+func (c *DatabaseConfig) Validate() error {
+	driver := c.GetDriver()
+	if driver != DriverMysql && driver != DriverSqlite && driver != DriverPostgres {
+		return fmt.Errorf("database config error, unsupported driver %s", c.Driver)
+	}
+	if c.ConnOption != nil && (c.ConnOption.MaxIdleConns < 0 || c.ConnOption.MaxOpenConns < 0) {
+		return errors.New("database config error, conn option should not be negative")
+	}
+	if err := c.validateReplicas(); err != nil {
+		return err
+	}
+	if len(c.DSN) > 0 {
+		return nil
+	}
+	if len(c.Database) == 0 {
+		return fmt.Errorf("%s config error, dsn or database is required", driver)
+	}
+	if driver != DriverSqlite && len(c.Host) == 0 {
+		return fmt.Errorf("%s config error, dsn or host is required", driver)
+	}
+	if c.Port < 0 {
+		return fmt.Errorf("%s config error, invalid port %d", driver, c.Port)
+	}
+	return nil
+}
+
+func (c *DatabaseConfig) validateReplicas() error {
+	if len(c.Replicas) == 0 {
+		return nil
+	}
+	if c.GetDriver() == DriverSqlite {
+		return errors.New("sqlite config error, replicas are not supported")
+	}
+	for i, dsn := range c.Replicas {
+		if len(dsn) == 0 {
+			return fmt.Errorf("%s config error, dsn of replica %d is empty", c.GetDriver(), i)
+		}
+	}
+	if c.ReplicaCheckIntervalSeconds < 0 {
+		return fmt.Errorf("%s config error, invalid replica check interval %d", c.GetDriver(), c.ReplicaCheckIntervalSeconds)
+	}
+	if c.ReplicaMaxLagSeconds < 0 {
+		return fmt.Errorf("%s config error, invalid replica max lag %d", c.GetDriver(), c.ReplicaMaxLagSeconds)
+	}
+	return nil
+}
+
+// ReplicaConfig 第 i 个副本的连接配置
+func (c *DatabaseConfig) ReplicaConfig(i int) *DatabaseConfig {
+	return &DatabaseConfig{
+		Driver:     c.Driver,
+		DSN:        c.Replicas[i],
+		ConnOption: c.ConnOption,
+	}
+}
+
+// GetReplicaCheckInterval 副本健康检查间隔
+func (c *DatabaseConfig) GetReplicaCheckInterval() time.Duration {
+	if c.ReplicaCheckIntervalSeconds <= 0 {
+		return DefaultReplicaCheckIntervalSeconds * time.Second
+	}
+	return time.Duration(c.ReplicaCheckIntervalSeconds) * time.Second
+}
+
+// GetReplicaMaxLag 副本允许的最大复制延迟
+func (c *DatabaseConfig) GetReplicaMaxLag() time.Duration {
+	if c.ReplicaMaxLagSeconds <= 0 {
+		return DefaultReplicaMaxLagSeconds * time.Second
+	}
+	return time.Duration(c.ReplicaMaxLagSeconds) * time.Second
+}
+
+func (c *DatabaseConfig) GetDriver() string {
+	if len(c.Driver) == 0 {
+		return DriverMysql
+	}
+	return c.Driver
+}
+
+// GetDSN 配置了 DSN 时直接使用，否则按驱动的格式生成
+func (c *DatabaseConfig) GetDSN() string {
+	if len(c.DSN) > 0 {
+		return c.DSN
+	}
+	params := url.Values{}
+	for k, v := range c.Params {
+		params.Set(k, v)
+	}
+	switch c.GetDriver() {
+	case DriverSqlite:
+		if len(params) == 0 {
+			return c.Database
+		}
+		return c.Database + "?" + params.Encode()
+	case DriverPostgres:
+		if !params.Has("sslmode") {
+			params.Set("sslmode", "disable")
+		}
+		u := url.URL{
+			Scheme:   DriverPostgres,
+			User:     url.UserPassword(c.User, c.Password),
+			Host:     c.addr(),
+			Path:     "/" + c.Database,
+			RawQuery: params.Encode(),
+		}
+		return u.String()
+	default:
+		// 参考 https://github.com/go-sql-driver/mysql#dsn-data-source-name
+		cfg := mysql.NewConfig()
+		cfg.User = c.User
+		cfg.Passwd = c.Password
+		cfg.Net = "tcp"
+		cfg.Addr = c.addr()
+		cfg.DBName = c.Database
+		cfg.ParseTime = true
+		cfg.Loc = time.Local
+		cfg.Params = map[string]string{"charset": "utf8mb4"}
+		for k, v := range c.Params {
+			cfg.Params[k] = v
+		}
+		return cfg.FormatDSN()
+	}
+}
+
+func (c *DatabaseConfig) addr() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPorts[c.GetDriver()]
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// GetConnOption 连接池配置，sqlite 同时只允许一个写连接，未配置时只使用一个连接
+func (c *DatabaseConfig) GetConnOption() *ConnectionOption {
+	if c.GetDriver() != DriverSqlite {
+		return c.ConnOption
+	}
+	option := ConnectionOption{}
+	if c.ConnOption != nil {
+		option = *c.ConnOption
+	}
+	if option.MaxOpenConns == 0 {
+		option.MaxOpenConns = 1
+	}
+	if option.MaxIdleConns == 0 {
+		option.MaxIdleConns = option.MaxOpenConns
+	}
+	return &option
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestDatabaseConfigSet 按驱动解析配置并生成 DSN
+func TestDatabaseConfigSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		driver   string
+		typeName string
+		dsn      string
+	}{
+		{
+			"mysql default driver",
+			`{"host":"db","user":"root","password":"pw","database":"aqua"}`,
+			DriverMysql, "MysqlConfig",
+			"root:pw@tcp(db:3306)/aqua?loc=Local&parseTime=true&charset=utf8mb4",
+		},
+		{
+			"mysql",
+			`{"driver":"mysql","host":"db","port":3307,"user":"root","password":"pw","database":"aqua","params":{"charset":"utf8"}}`,
+			DriverMysql, "MysqlConfig",
+			"root:pw@tcp(db:3307)/aqua?loc=Local&parseTime=true&charset=utf8",
+		},
+		{
+			"sqlite",
+			`{"driver":"sqlite","database":"aqua.db","params":{"_pragma":"foreign_keys(1)"}}`,
+			DriverSqlite, "DatabaseConfig",
+			"aqua.db?_pragma=foreign_keys%281%29",
+		},
+		{
+			"postgres",
+			`{"driver":"postgres","host":"db","user":"root","password":"pw","database":"aqua"}`,
+			DriverPostgres, "DatabaseConfig",
+			"postgres://root:pw@db:5432/aqua?sslmode=disable",
+		},
+		{
+			"dsn",
+			`{"driver":"postgres","dsn":"postgres://root@db/aqua"}`,
+			DriverPostgres, "DatabaseConfig",
+			"postgres://root@db/aqua",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultDatabaseConfig()
+			if err := cfg.Set(writeConfig(t, tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.GetDriver() != tt.driver || cfg.Type() != tt.typeName {
+				t.Fatalf("driver = %s, type = %s, want %s, %s", cfg.GetDriver(), cfg.Type(), tt.driver, tt.typeName)
+			}
+			if dsn := cfg.GetDSN(); dsn != tt.dsn {
+				t.Fatalf("dsn = %s, want %s", dsn, tt.dsn)
+			}
+		})
+	}
+}
+
+func TestDatabaseConfigValidate(t *testing.T) {
+	tests := map[string]string{
+		"unsupported driver":   `{"driver":"oracle","dsn":"x"}`,
+		"mysql without host":   `{"database":"aqua"}`,
+		"postgres without db":  `{"driver":"postgres","host":"db"}`,
+		"sqlite without db":    `{"driver":"sqlite"}`,
+		"sqlite with replicas": `{"driver":"sqlite","database":"aqua.db","replicas":["replica.db"]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultDatabaseConfig()
+			if err := cfg.Set(writeConfig(t, content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err == nil {
+				t.Fatal("validate should fail")
+			}
+		})
+	}
+}
+
+// TestServerConfigMysql 旧配置中的 mysql 仍然生效，database 优先
+func TestServerConfigMysql(t *testing.T) {
+	cfg := DefaultServerConfig()
+	if err := cfg.Set(writeConfig(t, `{"api":{},"mysql":{"host":"db","database":"aqua"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if db := cfg.GetDatabase(); db == nil || db.GetDriver() != DriverMysql || db.Type() != "MysqlConfig" {
+		t.Fatalf("database = %v, want legacy mysql config", db)
+	}
+	cfg = DefaultServerConfig()
+	content := `{"api":{},"mysql":{"host":"db","database":"aqua"},"database":{"driver":"sqlite","database":":memory:"}}`
+	if err := cfg.Set(writeConfig(t, content)); err != nil {
+		t.Fatal(err)
+	}
+	if db := cfg.GetDatabase(); db.GetDriver() != DriverSqlite {
+		t.Fatalf("database driver = %s, want %s", db.GetDriver(), DriverSqlite)
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	LogOutputStdout = "stdout"
+	LogOutputStderr = "stderr"
+)
+
+// LogRotation 日志文件切割，按大小和保留时间
+type LogRotation struct {
+	// 单个文件最大大小，单位MB
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// 旧文件最大保留天数
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// 旧文件最大保留个数
+	MaxBackups int  `json:"max_backups,omitempty"`
+	Compress   bool `json:"compress,omitempty"`
+}
+
+// LogSampling 日志采样，每秒相同日志前 Initial 条全部打印，之后每 Thereafter 条打印一条
+type LogSampling struct {
+	Initial    int `json:"initial" validate:"gte=0"`
+	Thereafter int `json:"thereafter" validate:"gte=0"`
+}
+
+type LogConfig struct {
+	Level string `json:"level" validate:"oneof=debug info warn error dpanic panic fatal"`
+	// json 或 console
+	Encoding string `json:"encoding" validate:"oneof=json console"`
+	// stdout、stderr 或文件路径
+	OutputPaths []string `json:"output_paths" validate:"min=1"`
+	// 开发模式，打印warn以上级别的堆栈
+	Development bool `json:"development,omitempty"`
+	// 只对文件输出生效
+	Rotation *LogRotation `json:"rotation,omitempty"`
+	Sampling *LogSampling `json:"sampling,omitempty"`
+}
+
+// DefaultLogConfig 未指定日志配置时使用debug模式输出到标准输出
+func DefaultLogConfig() *LogConfig {
+	return &LogConfig{
+		Level:       "debug",
+		Encoding:    "console",
+		OutputPaths: []string{LogOutputStdout},
+		Development: true,
+	}
+}
+
+func (c *LogConfig) Validate() error {
+	return validator.New().Struct(c)
+}
+
+func (c *LogConfig) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (c *LogConfig) Set(s string) error {
+	content, err := getConfigContent(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, c)
+}
+
+func (c *LogConfig) Type() string {
+	return "LogConfig"
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+// DefaultPurgeIntervalSeconds 默认每小时清理一次
+const DefaultPurgeIntervalSeconds = 3600
+
+// PurgeConfig 定时永久删除软删除超过保留时间的数据
+type PurgeConfig struct {
+	// 清理间隔，为0时使用默认值
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+	// key 为 domain.DomainPath 中的资源路由，例如 template，value 为软删除后保留的天数
+	RetentionDays map[string]int `json:"retention_days"`
+}
+
+func (c *PurgeConfig) Validate() error {
+	if c.IntervalSeconds < 0 {
+		return fmt.Errorf("purge config error, invalid interval %d", c.IntervalSeconds)
+	}
+	paths := map[string]any{}
+	for _, path := range domain.DomainPath {
+		paths[path] = struct{}{}
+	}
+	for resource, days := range c.RetentionDays {
+		if _, ok := paths[resource]; !ok {
+			return fmt.Errorf("purge config error, unknown resource %s", resource)
+		}
+		if days <= 0 {
+			return fmt.Errorf("purge config error, retention days of %s should be positive", resource)
+		}
+	}
+	return nil
+}
+
+// GetInterval 清理间隔秒数
+func (c *PurgeConfig) GetInterval() int {
+	if c.IntervalSeconds <= 0 {
+		return DefaultPurgeIntervalSeconds
+	}
+	return c.IntervalSeconds
+}
+
+func (c *PurgeConfig) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (c *PurgeConfig) Set(s string) error {
+	content, err := getConfigContent(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, c)
+}
+
+func (c *PurgeConfig) Type() string {
+	return "PurgeConfig"
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"net/http"
+	"strings"
+)
+
+// RBACAll 表示全部资源或全部操作
+const RBACAll = "*"
+
+var rbacVerbs = map[string]any{
+	RBACAll:           struct{}{},
+	http.MethodGet:    struct{}{},
+	http.MethodPost:   struct{}{},
+	http.MethodPut:    struct{}{},
+	http.MethodPatch:  struct{}{},
+	http.MethodDelete: struct{}{},
+}
+
+// RBACConfig 基于角色的权限控制，为空时不做权限控制
+type RBACConfig struct {
+	// 角色对应的权限，资源为 domain.DomainPath 中的路由，运维接口 /admin 的资源为 admin
+	Roles map[string][]domain.Permission `json:"roles,omitempty"`
+	// 配置中未定义的角色从数据库 domain.Role 中读取
+	LoadFromDB bool `json:"load_from_db,omitempty"`
+	// token认证的内部用户没有角色信息，使用这里配置的角色
+	TokenRoles []string `json:"token_roles,omitempty"`
+	// 未认证用户的角色，为空时未认证用户无法访问资源
+	AnonymousRoles []string `json:"anonymous_roles,omitempty"`
+}
+
+func (c *RBACConfig) Validate() error {
+	for role, perms := range c.Roles {
+		for _, p := range perms {
+			if len(p.Resource) == 0 {
+				return fmt.Errorf("rbac config error, role %s has permission without resource", role)
+			}
+			for _, verb := range p.Verbs {
+				if _, ok := rbacVerbs[strings.ToUpper(verb)]; !ok {
+					return fmt.Errorf("rbac config error, role %s has invalid verb %s", role, verb)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *RBACConfig) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (c *RBACConfig) Set(s string) error {
+	content, err := getConfigContent(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, c)
+}
+
+func (c *RBACConfig) Type() string {
+	return "RBACConfig"
+}
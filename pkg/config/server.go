@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 )
@@ -10,21 +11,67 @@ var (
 	LogConfigPath = ""
 )
 
-// TODO：Define the server configuration struct
 type ServerConfig struct {
+	Api      *ApiConfig      `json:"api"`
+	Database *DatabaseConfig `json:"database,omitempty"`
+	// Deprecated: 使用 Database，仍兼容旧配置中的 mysql
+	Mysql *MysqlConfig `json:"mysql,omitempty"`
+	// 权限控制，为空时不启用
+	RBAC *RBACConfig `json:"rbac,omitempty"`
+	// 定时清理软删除的数据，为空时不清理
+	Purge *PurgeConfig `json:"purge,omitempty"`
+	// 日志配置文件路径，命令行 --log-config-path 优先
+	LogConfigPath string `json:"log_config_path,omitempty"`
 }
 
 func DefaultServerConfig() *ServerConfig {
-	return &ServerConfig{}
+	return &ServerConfig{
+		Api: DefaultApiConfig(),
+	}
 }
 
 func (s *ServerConfig) Validate() error {
-	return nil
+	if s.Api == nil {
+		return errors.New("server config error, api config is required")
+	}
+	if s.GetDatabase() == nil {
+		return errors.New("server config error, database config is required")
+	}
+	if err := s.Api.Validate(); err != nil {
+		return err
+	}
+	if s.RBAC != nil {
+		if err := s.RBAC.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Purge != nil {
+		if err := s.Purge.Validate(); err != nil {
+			return err
+		}
+	}
+	return s.GetDatabase().Validate()
+}
+
+// GetDatabase database 优先，其次为旧配置中的 mysql
+func (s *ServerConfig) GetDatabase() *DatabaseConfig {
+	if s.Database != nil {
+		return s.Database
+	}
+	return s.Mysql
+}
+
+// GetLogConfigPath 命令行参数优先，其次为配置文件
+func (s *ServerConfig) GetLogConfigPath() string {
+	if len(LogConfigPath) > 0 {
+		return LogConfigPath
+	}
+	return s.LogConfigPath
 }
 
 func (s *ServerConfig) String() string {
 	b, err := json.Marshal(s)
-	if err == nil {
+	if err != nil {
 		panic(err)
 	}
 	return string(b)
@@ -1,9 +1,12 @@
 package dao
 
 import (
+	"fmt"
 	"github.com/MoWan-inc/aqua/pkg/config"
 	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
@@ -12,9 +15,28 @@ import (
 	"time"
 )
 
-func newDB(config *mysql.Config, option *config.ConnectionOption, customLog logger.Interface) (*gorm.DB, error) {
-	// 参考 https://github.com/go-sql-driver/mysql#dsn-data-source-name
-	// dsn := "user:password@tcp(localhost:5555)/dbname?charset=utf8mb4&parseTime=True&loc=Local"
+// newDialector 按驱动选择 gorm 方言，sqlite 使用纯 Go 实现，不依赖 cgo
+func newDialector(cfg *config.DatabaseConfig) (gorm.Dialector, error) {
+	dsn := cfg.GetDSN()
+	switch cfg.GetDriver() {
+	case config.DriverMysql:
+		// 参考 https://github.com/go-sql-driver/mysql#dsn-data-source-name
+		// dsn := "user:password@tcp(localhost:5555)/dbname?charset=utf8mb4&parseTime=True&loc=Local"
+		return mysql.New(mysql.Config{DSN: dsn}), nil
+	case config.DriverPostgres:
+		return postgres.Open(dsn), nil
+	case config.DriverSqlite:
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %s", cfg.Driver)
+	}
+}
+
+func newDB(cfg *config.DatabaseConfig, customLog logger.Interface) (*gorm.DB, error) {
+	dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var newLogger logger.Interface
 	if customLog != nil && !reflect.ValueOf(customLog).IsZero() {
 		newLogger = customLog
@@ -29,7 +51,7 @@ func newDB(config *mysql.Config, option *config.ConnectionOption, customLog logg
 		)
 	}
 
-	db, err := gorm.Open(mysql.New(*config), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: newLogger,
 	})
 	if err != nil {
@@ -40,26 +62,43 @@ func newDB(config *mysql.Config, option *config.ConnectionOption, customLog logg
 		return nil, err
 	}
 
-	if option != nil {
+	if option := cfg.GetConnOption(); option != nil {
 		// 设置连接池连接最大数量
 		sqlDB.SetMaxIdleConns(option.MaxIdleConns)
 		// 设置数据库最大打开连接数
 		sqlDB.SetMaxOpenConns(option.MaxOpenConns)
 		// 设置连接最大可复用时间
 		sqlDB.SetConnMaxLifetime(option.ConnMaxLifeTime)
+		// 设置连接最大空闲时间
+		sqlDB.SetConnMaxIdleTime(option.ConnMaxIdleTime)
 	}
 	return db, nil
 }
 
-func NewDAO(cfg config.MysqlConfig) aquadao.DAO {
+func NewDAO(cfg config.DatabaseConfig) aquadao.DAO {
 	return NewBaseDAO(cfg)
 }
 
-func NewBaseDAO(cfg config.MysqlConfig) *aquadao.BaseDAO {
+func NewBaseDAO(cfg config.DatabaseConfig) *aquadao.BaseDAO {
 	// todo: 从配置文件中读取配置初始化logger
-	db, err := newDB(&mysql.Config{DSN: cfg.DSN}, cfg.ConnOption, nil)
+	db, err := newDB(&cfg, nil)
 	if err != nil {
 		panic(err)
 	}
-	return aquadao.NewBaseDAO(db)
+	baseDAO := aquadao.NewBaseDAO(db)
+	if len(cfg.Replicas) == 0 {
+		return baseDAO
+	}
+	replicas := make([]*gorm.DB, 0, len(cfg.Replicas))
+	for i := range cfg.Replicas {
+		replica, err := newDB(cfg.ReplicaConfig(i), nil)
+		if err != nil {
+			panic(fmt.Errorf("open replica %d error: %w", i, err))
+		}
+		replicas = append(replicas, replica)
+	}
+	if err = baseDAO.UseReplicas(cfg.GetReplicaCheckInterval(), cfg.GetReplicaMaxLag(), replicas...); err != nil {
+		panic(err)
+	}
+	return baseDAO
 }
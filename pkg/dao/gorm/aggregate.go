@@ -0,0 +1,72 @@
+package gorm
+
+import (
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+)
+
+// 聚合查询最多返回的分组数
+const maxAggregateRows = 1000
+
+// TooManyGroupsError 未分页的聚合查询分组数超过 maxAggregateRows，需要缩小过滤范围或分页查询
+var TooManyGroupsError = fmt.Errorf("more than %d groups", maxAggregateRows)
+
+// prepareAggregate 构造聚合查询的 SELECT 和 GROUP BY，结果按分组列排序
+func prepareAggregate(agg *api.Aggregation, fields map[string]any, result *gorm.DB) *gorm.DB {
+	if err := agg.Validate(fields); err != nil {
+		_ = result.AddError(err)
+		return result
+	}
+	sql := make([]string, 0, len(agg.GroupBy)+len(agg.Metrics))
+	vars := make([]any, 0)
+	groups := make([]clause.Column, 0, len(agg.GroupBy))
+	for _, g := range agg.GroupBy {
+		column, err := gormColumn(fields, g.Field)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
+		}
+		alias := clause.Column{Name: g.Alias()}
+		if len(g.Bucket) > 0 {
+			sql = append(sql, "? AS ?")
+			vars = append(vars, timeBucket{Column: column, Bucket: g.Bucket}, alias)
+		} else {
+			sql = append(sql, "? AS ?")
+			vars = append(vars, column, alias)
+		}
+		groups = append(groups, alias)
+	}
+	for _, m := range agg.Metrics {
+		alias := clause.Column{Name: m.Alias()}
+		if len(m.Field) == 0 {
+			sql = append(sql, "COUNT(*) AS ?")
+			vars = append(vars, alias)
+			continue
+		}
+		column, err := gormColumn(fields, m.Field)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
+		}
+		switch m.Func {
+		case api.AggCountDistinct:
+			sql = append(sql, "COUNT(DISTINCT ?) AS ?")
+		default:
+			sql = append(sql, fmt.Sprintf("%s(?) AS ?", strings.ToUpper(m.Func)))
+		}
+		vars = append(vars, column, alias)
+	}
+	result = result.Select(strings.Join(sql, ", "), vars...)
+	if len(groups) > 0 {
+		result = result.Clauses(clause.GroupBy{Columns: groups})
+		orders := make([]clause.OrderByColumn, 0, len(groups))
+		for _, g := range groups {
+			orders = append(orders, clause.OrderByColumn{Column: g})
+		}
+		result = result.Order(clause.OrderBy{Columns: orders})
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+func TestAggregateTooManyGroups(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	roles := make([]*domain.Role, 0, maxAggregateRows+1)
+	for i := 0; i <= maxAggregateRows; i++ {
+		roles = append(roles, &domain.Role{Name: "role-" + strconv.Itoa(i)})
+	}
+	if err := d.CreateBatch(ctx, &roles); err != nil {
+		t.Fatal(err)
+	}
+	agg := (&api.AggregateRequest{GroupBy: "name"}).Parse()
+	if _, err := d.Aggregate(ctx, &api.QueryRequest{Query: &domain.Role{}}, agg); !errors.Is(err, TooManyGroupsError) {
+		t.Fatalf("aggregate error = %v, want %v", err, TooManyGroupsError)
+	}
+	// 分页时按页返回
+	q := &api.QueryRequest{Query: &domain.Role{}, Pagination: api.Pagination{Page: 1, PageSize: 10}}
+	rows, err := d.Aggregate(ctx, q, agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 10 {
+		t.Fatalf("aggregate page rows = %d, want 10", len(rows))
+	}
+	if _, err = d.Delete(ctx, &domain.Role{Name: "role-0"}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = d.Aggregate(ctx, &api.QueryRequest{Query: &domain.Role{}}, agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != maxAggregateRows {
+		t.Fatalf("aggregate rows = %d, want %d", len(rows), maxAggregateRows)
+	}
+}
@@ -6,30 +6,46 @@ import (
 	"fmt"
 	"github.com/MoWan-inc/aqua/pkg/api"
 	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/MoWan-inc/aqua/pkg/util/object"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"reflect"
 	"strings"
+	"time"
 )
 
 const (
 	BaseDAOName = "BaseDAO"
+	// LoggerName 数据库操作的命名日志，可以通过管理接口单独调整级别
+	LoggerName = "dao"
 )
 
 var (
 	NotExistsError = errors.New("not exists")
+	// EmptyConditionError 不带条件的批量删除
+	EmptyConditionError = errors.New("empty condition")
 )
 
 var _ DAO = &BaseDAO{}
 
 type BaseDAO struct {
 	conn *gorm.DB
+	// 只读副本，见 UseReplicas
+	replicas *replicaSet
 }
 
 func NewBaseDAO(db *gorm.DB) *BaseDAO {
+	// 提前创建命名日志，启动后即可调整级别
+	log.Named(LoggerName)
 	return &BaseDAO{conn: db}
 }
 
+// daoLogger 请求日志对应的命名日志，保留 request id 等字段
+func daoLogger(ctx context.Context) *log.Logger {
+	return log.FromContext(ctx).Named(LoggerName)
+}
+
 func (b *BaseDAO) Name() string {
 	return BaseDAOName
 }
@@ -54,130 +70,247 @@ func (b *BaseDAO) Session() *gorm.DB {
 	return b.conn
 }
 
+// Shutdown 关闭连接池，实现 do.Shutdownable
+func (b *BaseDAO) Shutdown() error {
+	sqlDB, err := b.conn.DB()
+	if err != nil {
+		return err
+	}
+	if b.replicas != nil {
+		return errors.Join(b.replicas.close(), sqlDB.Close())
+	}
+	return sqlDB.Close()
+}
+
 func (b *BaseDAO) Count(ctx context.Context, q *api.QueryRequest, opts ...OptionFunc) (count int64, err error) {
-	result := b.conn.WithContext(ctx)
+	result := b.reader(ctx)
 	for _, o := range opts {
 		result = o(result)
 	}
 	result = result.Where(q.Query).Model(q.Query)
 	// filter
-	result = prepareFieldFilter(&q.Filter, result)
+	result = prepareFieldFilter(q, result)
+	result = prepareFilterExpr(q, result)
 	if !object.IsEmpty(q.Not) {
 		result.Not(q.Not)
 	}
 	result.Count(&count)
 	if result.Error != nil {
-		// todo 日志记录
+		daoLogger(ctx).Errorw("base dao count error", "query", q, "error", result.Error)
 		return 0, result.Error
 	}
 	return
 }
 
+func (b *BaseDAO) Aggregate(ctx context.Context, q *api.QueryRequest, agg *api.Aggregation, opts ...OptionFunc) ([]map[string]any, error) {
+	result := b.reader(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	result = result.Where(q.Query).Model(q.Query)
+	// filter
+	result = prepareFieldFilter(q, result)
+	result = prepareFilterExpr(q, result)
+	if !object.IsEmpty(q.Not) {
+		result.Not(q.Not)
+	}
+	result = prepareAggregate(agg, domain.GetGormFields(object.ClassName(q.Query)), result)
+	if q.PageSize > 0 {
+		result = prepareLimit(&q.Pagination, result)
+	} else {
+		// 多查询一行，判断分组是否超过上限
+		result = result.Limit(maxAggregateRows + 1)
+	}
+	rows := make([]map[string]any, 0)
+	result.Find(&rows)
+	if result.Error != nil {
+		daoLogger(ctx).Errorw("base dao aggregate error", "query", q, "error", result.Error)
+		return nil, fmt.Errorf("base dao aggregate %v error: %w", q, result.Error)
+	}
+	if q.PageSize <= 0 && len(rows) > maxAggregateRows {
+		return nil, fmt.Errorf("base dao aggregate %v error: %w", q, TooManyGroupsError)
+	}
+	return rows, nil
+}
+
 func (b *BaseDAO) List(ctx context.Context, q *api.QueryRequest, results any, opts ...OptionFunc) error {
-	result := b.conn.WithContext(ctx)
+	result := b.reader(ctx)
 	opts = append(opts, GetOptions(q.Query)...)
 	for _, o := range opts {
 		result = o(result)
 	}
 	result = result.Where(q.Query)
 	// filter, pagination, sorting
-	result = prepareFieldFilter(&q.Filter, result)
+	result = prepareFieldFilter(q, result)
+	result = prepareFilterExpr(q, result)
 	if !object.IsEmpty(q.Not) {
 		result.Not(q.Not)
 	}
+	fields := domain.GetGormFields(object.ClassName(q.Query))
+	keys, err := sortKeys(q, fields)
+	if err != nil {
+		_ = result.AddError(err)
+	}
+	// 游标需要排序列的值
+	columns := q.Columns()
+	if len(columns) > 0 {
+		for _, key := range keys {
+			columns = append(columns, key.Field)
+		}
+	}
+	result = prepareSelect(columns, fields, result)
+	result = prepareCursor(q, keys, fields, result)
 	result = prepareLimit(&q.Pagination, result)
-	result = prepareSorting(&q.Sorting, result)
+	result = prepareSorting(keys, fields, result)
 	result.Find(results)
 	if result.Error != nil {
-		// todo 添加日志
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil
 		}
+		daoLogger(ctx).Errorw("base dao list error", "query", q, "error", result.Error)
 		return fmt.Errorf("base dao list %v error: %w", q, result.Error)
 	}
+	if q.NextCursor, err = nextCursor(ctx, q, keys, results, result); err != nil {
+		daoLogger(ctx).Errorw("base dao list next cursor error", "query", q, "error", err)
+		return err
+	}
 	return nil
 }
 
 func (b *BaseDAO) Get(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
-	result := b.conn.WithContext(ctx)
+	result := b.reader(ctx)
 	opts = append(opts, GetOptions(obj)...)
 	for _, o := range opts {
 		result = o(result)
 	}
 	result = result.Where(obj).First(obj)
 	if result.Error != nil {
-		// todo 日志
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return NotExistsError
 		}
+		daoLogger(ctx).Errorw("base dao get error", "object", obj, "error", result.Error)
 		return fmt.Errorf("base dao get %v error: %w", obj, result.Error)
 	}
 	return nil
 }
 
-func (b *BaseDAO) ListWithInClause(ctx context.Context, results any, query string, inClause [][]any) error {
-	result := b.conn.WithContext(ctx)
+func (b *BaseDAO) ListWithInClause(ctx context.Context, results any, query string, inClause [][]any, opts ...OptionFunc) error {
+	result := b.reader(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
 	result = result.Where(query, inClause)
 	result.Find(results)
 	if result.Error != nil {
-		// todo 日志
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil
 		}
+		daoLogger(ctx).Errorw("base dao list with in clause error", "query", query, "error", result.Error)
 		return fmt.Errorf("base dao list %v with in clause %v error: %w", query, inClause, result.Error)
 	}
 	return nil
 }
 
-func (b *BaseDAO) Delete(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
-	result := b.conn.WithContext(ctx)
+func (b *BaseDAO) Delete(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) (int64, error) {
+	// 不允许不带任何条件删除全表
+	if object.IsEmpty(obj) {
+		return 0, fmt.Errorf("base dao delete error: %w", EmptyConditionError)
+	}
+	result := b.db(ctx)
 	for _, o := range opts {
 		result = o(result)
 	}
-	// 如果有级联删除的对象，一起删除
-	if result.Error != nil {
-		// todo 日志
-		return result.Error
+	associations, err := cascadeAssociations(result, obj)
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao delete error", "object", obj, "error", err)
+		return 0, err
 	}
-	return nil
+	if len(associations) == 0 {
+		result = result.Where(obj).Delete(obj)
+		if result.Error != nil {
+			daoLogger(ctx).Errorw("base dao delete error", "object", obj, "error", result.Error)
+			return 0, fmt.Errorf("base dao delete %v error: %w", obj, result.Error)
+		}
+		return result.RowsAffected, nil
+	}
+	// 级联删除按主键进行，先找出满足条件的数据再逐条删除
+	var affected int64
+	err = result.Transaction(func(tx *gorm.DB) error {
+		// 关联和主对象使用相同的删除时间，恢复时据此只恢复级联删除的关联
+		now := tx.NowFunc()
+		tx = tx.Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+		if err := tx.Where(obj).Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		for i := 0; i < rows.Elem().Len(); i++ {
+			deleted := tx.Select(associations).Delete(rows.Elem().Index(i).Interface())
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			affected += deleted.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao delete error", "object", obj, "error", err)
+		return 0, fmt.Errorf("base dao delete %v error: %w", obj, err)
+	}
+	return affected, nil
 }
 
 func (b *BaseDAO) Create(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
-	result := b.conn.WithContext(ctx)
+	result := b.db(ctx)
 	for _, o := range opts {
 		result = o(result)
 	}
-	result = b.conn.Create(obj)
+	if v, ok := obj.(domain.Versioned); ok && v.GetVersion() == 0 {
+		v.SetVersion(1)
+	}
+	result = result.Create(obj)
 	if result.Error != nil {
-		// todo 日志
+		daoLogger(ctx).Errorw("base dao create error", "object", obj, "error", result.Error)
 		return result.Error
 	}
 	return nil
 }
 
 func (b *BaseDAO) Update(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
-	// 增量覆盖更新，先找寻更新对象
-	if err := b.updateByIndexer(obj); err != nil {
-		return err
-	}
-	result := b.conn.WithContext(ctx)
+	result := b.db(ctx)
 	for _, o := range opts {
 		result = o(result)
 	}
+	// 增量覆盖更新，先找寻更新对象
+	if err := updateByIndexer(ctx, result, obj); err != nil {
+		return err
+	}
 
 	key := reflect.ValueOf(obj.Key())
 	if key.IsZero() {
 		return NotExistsError
 	}
+	result, checked, err := b.prepareVersion(ctx, result, obj)
+	if err != nil {
+		return err
+	}
 	result = result.Updates(obj)
 	if result.Error != nil {
-		// todo 日志
+		rollbackVersion(obj)
+		daoLogger(ctx).Errorw("base dao update error", "object", obj, "error", result.Error)
 		return result.Error
 	}
+	if checked && result.RowsAffected == 0 {
+		rollbackVersion(obj)
+		return &ConflictError{Key: obj.Key()}
+	}
 	return nil
 }
 
-func (b *BaseDAO) updateByIndexer(q domain.Indexer) error {
+/*
+updateByIndexer 按唯一索引找到主键，查询使用调用方的选项（锁、软删除范围等）
+默认不包含已软删除的数据，唯一索引只匹配到已软删除的数据时返回 DeletedConflictError，使用 SoftDeleteOption 时可以更新
+*/
+func updateByIndexer(ctx context.Context, result *gorm.DB, q domain.Indexer) error {
 	// 主键存在则不需要找寻
 	if !reflect.ValueOf(q.Key()).IsZero() {
 		return nil
@@ -190,69 +323,187 @@ func (b *BaseDAO) updateByIndexer(q domain.Indexer) error {
 	if indexer == nil {
 		return nil
 	}
-	result := b.conn.Unscoped().Where(indexer).First(indexer)
+	// 新的会话复制已应用的选项，不影响之后的更新语句，写之前的查询总是读主库
+	base := PrimaryOption()(result.Session(&gorm.Session{}))
+	result = base.Session(&gorm.Session{}).Where(indexer).First(indexer)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		deleted, err := deletedByIndexer(base, indexer)
+		if err != nil {
+			daoLogger(ctx).Errorw("base dao find by unique index error", "index", indexer, "error", err)
+			return err
+		}
+		if deleted {
+			return fmt.Errorf("base dao find by unique index %v error: %w", indexer, DeletedConflictError)
+		}
 		return nil
 	}
 	if result.Error != nil {
-		// todo 日志
+		daoLogger(ctx).Errorw("base dao find by unique index error", "index", indexer, "error", result.Error)
 		return result.Error
 	}
 	if !reflect.ValueOf(indexer.Key()).IsZero() {
 		// 存在单一索引，就更新该值
 		if err := q.SetKey(indexer.Key()); err != nil {
-			// todo 日志
+			daoLogger(ctx).Errorw("base dao set key by unique index error", "index", indexer, "error", err)
 			return err
 		}
 	}
 	return nil
 }
 
+// deletedByIndexer 唯一索引是否与已软删除的数据冲突，查询已经包含软删除数据时不需要检查
+func deletedByIndexer(base *gorm.DB, indexer domain.Indexer) (bool, error) {
+	if base.Statement.Unscoped {
+		return false, nil
+	}
+	s, err := parseSchema(base, indexer)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := s.FieldsByDBName[deletedAtColumn]; !ok {
+		return false, nil
+	}
+	var count int64
+	err = DeletedOnlyOption()(base.Session(&gorm.Session{}).Model(indexer).Where(indexer)).Count(&count).Error
+	return count > 0, err
+}
+
 func (b *BaseDAO) Save(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
 	// save表示全量更新，先找寻更新对象，没有找到则创建，这里由gorm的save实现
-	if err := b.updateByIndexer(obj); err != nil {
+	if err := updateByIndexer(ctx, result, obj); err != nil {
 		return err
 	}
-	result := b.conn.WithContext(ctx)
-	for _, o := range opts {
-		result = o(result)
+	v, versioned := obj.(domain.Versioned)
+	_, unmodified := result.Get(ifUnmodifiedKey)
+	if isNew := reflect.ValueOf(obj.Key()).IsZero(); isNew || (!versioned && !unmodified) {
+		if isNew && versioned {
+			v.SetVersion(1)
+		}
+		result = result.Save(obj)
+		if result.Error != nil {
+			daoLogger(ctx).Errorw("base dao save error", "object", obj, "error", result.Error)
+			return result.Error
+		}
+		return nil
 	}
-	result = b.conn.Save(obj)
+	// 乐观锁检查失败时 gorm 的 Save 会改为创建，这里只做全量更新
+	result, _, err := b.prepareVersion(ctx, result, obj)
+	if err != nil {
+		return err
+	}
+	result = result.Select("*").Updates(obj)
 	if result.Error != nil {
-		// todo 日志
+		rollbackVersion(obj)
+		daoLogger(ctx).Errorw("base dao save error", "object", obj, "error", result.Error)
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		rollbackVersion(obj)
+		return &ConflictError{Key: obj.Key()}
+	}
 	return nil
 }
 
-func prepareFieldFilter(filter *api.Filter, result *gorm.DB) *gorm.DB {
-	if len(filter.Filters) > 0 && len(filter.Filters) > 0 {
-		filter.Filters = fmt.Sprintf("%%%v%%", filter.Filters)
-		clauses := make([]string, 0)
-		params := make([]any, 0)
-		for _, f := range strings.Split(filter.Fields, ",") {
-			clauses = append(clauses, fmt.Sprintf("%v LIKE ?", f))
-			params = append(params, filter.Filters)
+// cascadeAssociations 模型通过 domain.Preload 和 domain.Relation 声明的关联，删除时一起删除
+func cascadeAssociations(db *gorm.DB, obj any) ([]string, error) {
+	names := make([]string, 0)
+	if p, ok := obj.(domain.Preload); ok {
+		names = append(names, p.Preloads()...)
+	}
+	r, ok := obj.(domain.Relation)
+	if !ok && len(names) == 0 {
+		return nil, nil
+	}
+	s, err := parseSchema(db, obj)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		for _, join := range r.Joins() {
+			if name, ok := join.(string); ok {
+				names = append(names, name)
+				continue
+			}
+			// 关联对象，找到对应的关联字段
+			for name, rel := range s.Relationships.Relations {
+				if rel.FieldSchema != nil && rel.FieldSchema.ModelType == reflect.Indirect(reflect.ValueOf(join)).Type() {
+					names = append(names, name)
+				}
+			}
 		}
-		result = result.Where(strings.Join(clauses, " OR "), params...)
 	}
-	return result
+	associations := make([]string, 0, len(names))
+	seen := map[string]any{}
+	for _, name := range names {
+		// 嵌套预加载只取第一层
+		name, _, _ = strings.Cut(name, ".")
+		if _, ok := s.Relationships.Relations[name]; !ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		associations = append(associations, name)
+	}
+	return associations, nil
 }
 
-func prepareLimit(pagination *api.Pagination, result *gorm.DB) *gorm.DB {
-	if pagination.PageSize > 0 {
-		result = result.Limit(pagination.PageSize).Offset((pagination.Page - 1) * pagination.PageSize)
+// prepareFieldFilter 模糊匹配，列名只能来自模型的gorm列，不依赖调用方事先校验
+func prepareFieldFilter(q *api.QueryRequest, result *gorm.DB) *gorm.DB {
+	if len(q.Fields) == 0 || len(q.Filters) == 0 {
+		return result
 	}
-	return result
+	fields := domain.GetGormFields(object.ClassName(q.Query))
+	value := fmt.Sprintf("%%%v%%", q.Filters)
+	exprs := make([]clause.Expression, 0)
+	for _, f := range strings.Split(q.Fields, ",") {
+		column, err := gormColumn(fields, f)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
+		}
+		exprs = append(exprs, iLike{Column: column, Value: value})
+	}
+	return result.Where(clause.Or(exprs...))
 }
 
-func prepareSorting(sorting *api.Sorting, result *gorm.DB) *gorm.DB {
-	if len(sorting.SortBy) > 0 {
-		order := sorting.SortBy
-		if sorting.SortDesc {
-			order += " DESC"
+// prepareSelect 只查询指定的列，主键总会被查询以便关联预加载
+func prepareSelect(columns []string, fields map[string]any, result *gorm.DB) *gorm.DB {
+	if len(columns) == 0 {
+		return result
+	}
+	if _, ok := fields[primaryKeyColumn]; ok {
+		columns = append([]string{primaryKeyColumn}, columns...)
+	}
+	selected := make([]clause.Column, 0, len(columns))
+	seen := map[string]any{}
+	for _, c := range columns {
+		column, err := gormColumn(fields, c)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
 		}
-		result = result.Order(order)
+		if _, ok := seen[column.Name]; ok {
+			continue
+		}
+		seen[column.Name] = struct{}{}
+		selected = append(selected, column)
+	}
+	return result.Clauses(clause.Select{Columns: selected})
+}
+
+func prepareLimit(pagination *api.Pagination, result *gorm.DB) *gorm.DB {
+	// 游标分页不需要偏移
+	if pagination.PageSize > 0 && len(pagination.Cursor) > 0 {
+		return result.Limit(pagination.PageSize)
+	}
+	if pagination.PageSize > 0 {
+		result = result.Limit(pagination.PageSize).Offset((pagination.Page - 1) * pagination.PageSize)
 	}
 	return result
 }
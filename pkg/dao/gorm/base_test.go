@@ -0,0 +1,383 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录执行（DryRun 时为生成）的 SQL
+type sqlRecorder struct {
+	logger.Interface
+	mu   sync.Mutex
+	sqls []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sqls = append(r.sqls, sql)
+}
+
+func (r *sqlRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sqls = nil
+}
+
+func (r *sqlRecorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sqls...)
+}
+
+// newSqliteDAO 内存数据库，只使用一个连接保证各操作看到同一个数据库
+func newSqliteDAO(t *testing.T) (*BaseDAO, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = db.AutoMigrate(&domain.Role{}, &domain.Template{}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+	return NewBaseDAO(db), recorder
+}
+
+// newDryRunDAO 只生成 SQL 不执行，dialector 为 nil 时使用 sqlite
+func newDryRunDAO(t *testing.T, dialector gorm.Dialector) (*BaseDAO, *sqlRecorder) {
+	t.Helper()
+	if dialector == nil {
+		dialector = sqlite.Open(":memory:")
+	}
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBaseDAO(db), recorder
+}
+
+func newPostgresDryRunDAO(t *testing.T) (*BaseDAO, *sqlRecorder) {
+	return newDryRunDAO(t, postgres.New(postgres.Config{DSN: "host=localhost"}))
+}
+
+// lastSQL 最后一条以 prefix 开头的语句
+func lastSQL(t *testing.T, recorder *sqlRecorder, prefix string) string {
+	t.Helper()
+	sqls := recorder.statements()
+	for i := len(sqls) - 1; i >= 0; i-- {
+		if strings.HasPrefix(sqls[i], prefix) {
+			return sqls[i]
+		}
+	}
+	t.Fatalf("no %s statement in %q", prefix, sqls)
+	return ""
+}
+
+func assertSQL(t *testing.T, sql string, contains []string, excludes []string) {
+	t.Helper()
+	for _, s := range contains {
+		if !strings.Contains(sql, s) {
+			t.Errorf("sql %q should contain %q", sql, s)
+		}
+	}
+	for _, s := range excludes {
+		if strings.Contains(sql, s) {
+			t.Errorf("sql %q should not contain %q", sql, s)
+		}
+	}
+}
+
+func omitOption(columns ...string) OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Omit(columns...)
+	}
+}
+
+func TestCreateOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []OptionFunc
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default",
+			contains: []string{"INSERT INTO `roles`", "`name`", "`permissions`"},
+		},
+		{
+			name:     "omit",
+			opts:     []OptionFunc{omitOption("permissions")},
+			contains: []string{"INSERT INTO `roles`", "`name`"},
+			excludes: []string{"`permissions`"},
+		},
+		{
+			name:     "omit association",
+			opts:     []OptionFunc{OmitAssociationOption()},
+			contains: []string{"INSERT INTO `roles`", "`name`", "`permissions`"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, recorder := newDryRunDAO(t, nil)
+			if err := d.Create(context.Background(), &domain.Role{Name: "admin"}, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			assertSQL(t, lastSQL(t, recorder, "INSERT"), tt.contains, tt.excludes)
+		})
+	}
+}
+
+func TestSaveOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []OptionFunc
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default",
+			contains: []string{"UPDATE `roles` SET", "`name`=", "`permissions`=", "`id` = 1"},
+		},
+		{
+			name:     "omit",
+			opts:     []OptionFunc{omitOption("permissions")},
+			contains: []string{"UPDATE `roles` SET", "`name`="},
+			excludes: []string{"`permissions`="},
+		},
+		{
+			name:     "soft delete scope",
+			opts:     []OptionFunc{SoftDeleteOption()},
+			contains: []string{"UPDATE `roles` SET"},
+			excludes: []string{"`deleted_at` IS NULL"},
+		},
+		{
+			name:     "if unmodified",
+			opts:     []OptionFunc{IfUnmodifiedOption(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))},
+			contains: []string{"`roles`.`updated_at` >= \"2024-01-02 03:04:05\"", "`roles`.`updated_at` < \"2024-01-02 03:04:05.002\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, recorder := newDryRunDAO(t, nil)
+			role := &domain.Role{Name: "admin"}
+			role.ID = 1
+			// DryRun 时没有影响的行，乐观锁检查返回冲突，这里只检查生成的语句
+			var conflict *ConflictError
+			if err := d.Save(context.Background(), role, tt.opts...); err != nil && !errors.As(err, &conflict) {
+				t.Fatal(err)
+			}
+			assertSQL(t, lastSQL(t, recorder, "UPDATE"), tt.contains, tt.excludes)
+		})
+	}
+}
+
+func TestUpdateOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []OptionFunc
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default",
+			contains: []string{"UPDATE `roles` SET", "`name`=\"ops\"", "`roles`.`deleted_at` IS NULL", "`id` = 1"},
+			excludes: []string{"`permissions`="},
+		},
+		{
+			name:     "soft delete scope",
+			opts:     []OptionFunc{SoftDeleteOption()},
+			contains: []string{"UPDATE `roles` SET", "`name`=\"ops\""},
+			excludes: []string{"`deleted_at` IS NULL"},
+		},
+		{
+			name:     "omit",
+			opts:     []OptionFunc{omitOption("name")},
+			contains: []string{"UPDATE `roles` SET `updated_at`="},
+			excludes: []string{"`name`="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, recorder := newDryRunDAO(t, nil)
+			role := &domain.Role{Name: "ops"}
+			role.ID = 1
+			if err := d.Update(context.Background(), role, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			assertSQL(t, lastSQL(t, recorder, "UPDATE"), tt.contains, tt.excludes)
+		})
+	}
+}
+
+// TestUniqueIndexLookupOptions 按唯一索引查找主键时使用调用方的选项
+func TestUniqueIndexLookupOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []OptionFunc
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default",
+			contains: []string{`SELECT * FROM "roles" WHERE "roles"."name" = 'ops'`, `"roles"."deleted_at" IS NULL`},
+			excludes: []string{"FOR UPDATE"},
+		},
+		{
+			name:     "lock",
+			opts:     []OptionFunc{LockRowUpdateOption()},
+			contains: []string{`"roles"."deleted_at" IS NULL`, "FOR UPDATE"},
+		},
+		{
+			name:     "soft delete scope",
+			opts:     []OptionFunc{SoftDeleteOption()},
+			contains: []string{`SELECT * FROM "roles" WHERE "roles"."name" = 'ops'`},
+			excludes: []string{"deleted_at"},
+		},
+		{
+			name:     "select",
+			opts:     []OptionFunc{SelectOption(&domain.Role{}, "name")},
+			contains: []string{`SELECT "roles"."id","roles"."name" FROM "roles"`},
+		},
+	}
+	for _, tt := range tests {
+		for _, op := range []string{"update", "save"} {
+			t.Run(tt.name+" "+op, func(t *testing.T) {
+				d, recorder := newPostgresDryRunDAO(t)
+				role := &domain.Role{Name: "ops"}
+				var err error
+				if op == "update" {
+					err = d.Update(context.Background(), role, tt.opts...)
+				} else {
+					err = d.Save(context.Background(), role, tt.opts...)
+				}
+				// DryRun 时找不到数据，Update 返回 NotExistsError，Save 改为创建
+				if err != nil && !errors.Is(err, NotExistsError) {
+					t.Fatal(err)
+				}
+				assertSQL(t, lastSQL(t, recorder, "SELECT"), tt.contains, tt.excludes)
+			})
+		}
+	}
+}
+
+// TestUpdateSoftDeleted 默认不会通过唯一索引找到并更新已软删除的数据，返回 DeletedConflictError
+func TestUpdateSoftDeleted(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	role := &domain.Role{Name: "ops"}
+	if err := d.Create(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Delete(ctx, &domain.Role{Name: "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Update(ctx, &domain.Role{Name: "ops"}); !errors.Is(err, DeletedConflictError) {
+		t.Fatalf("update soft deleted error = %v, want %v", err, DeletedConflictError)
+	}
+	updated := &domain.Role{Name: "ops", Permissions: []domain.Permission{{Resource: "template"}}}
+	if err := d.Update(ctx, updated, SoftDeleteOption()); err != nil {
+		t.Fatal(err)
+	}
+	if updated.ID != role.ID {
+		t.Fatalf("update soft deleted with SoftDeleteOption id = %d, want %d", updated.ID, role.ID)
+	}
+	found := &domain.Role{Name: "ops"}
+	if err := d.Get(ctx, found); !errors.Is(err, NotExistsError) {
+		t.Fatalf("get soft deleted error = %v, want %v", err, NotExistsError)
+	}
+}
+
+// TestSaveSoftDeleted 按唯一索引保存时不插入与已软删除数据冲突的行
+func TestSaveSoftDeleted(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	role := &domain.Role{Name: "ops"}
+	if err := d.Create(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Delete(ctx, &domain.Role{Name: "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Save(ctx, &domain.Role{Name: "ops"}); !errors.Is(err, DeletedConflictError) {
+		t.Fatalf("save soft deleted error = %v, want %v", err, DeletedConflictError)
+	}
+	var count int64
+	if err := d.conn.Unscoped().Model(&domain.Role{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("rows after save soft deleted = %d, want 1", count)
+	}
+	// 没有冲突的唯一索引仍然插入
+	if err := d.Save(ctx, &domain.Role{Name: "dev"}); err != nil {
+		t.Fatalf("save new role error = %v", err)
+	}
+}
+
+func TestWriteContextCanceled(t *testing.T) {
+	d, recorder := newSqliteDAO(t)
+	if err := d.Create(context.Background(), &domain.Role{Name: "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	withID := func() *domain.Role {
+		role := &domain.Role{Name: "ops"}
+		role.ID = 1
+		return role
+	}
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"create", func() error { return d.Create(ctx, &domain.Role{Name: "dev"}) }},
+		{"update", func() error { return d.Update(ctx, withID()) }},
+		{"update by unique index", func() error { return d.Update(ctx, &domain.Role{Name: "ops"}) }},
+		{"save", func() error { return d.Save(ctx, withID()) }},
+		{"save by unique index", func() error { return d.Save(ctx, &domain.Role{Name: "ops"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, context.Canceled) {
+				t.Fatalf("error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+	var count int64
+	if err := d.Session().Model(&domain.Role{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("count = %d, want 1", count)
+	}
+}
@@ -0,0 +1,187 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"gorm.io/gorm"
+	"reflect"
+	"strings"
+)
+
+// DefaultBatchSize 批量写入时每条语句的行数，可以用 BatchSizeOption 修改
+const DefaultBatchSize = 500
+
+const (
+	batchSavePoint = "aqua_batch"
+	rowSavePoint   = "aqua_batch_row"
+)
+
+// RowError 批量写入中单行的错误，Index 为该行在输入中的下标
+type RowError struct {
+	Index int
+	Err   error
+}
+
+// BatchError 批量写入失败的行，整批数据已回滚
+type BatchError struct {
+	Rows []RowError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Rows))
+	for _, r := range e.Rows {
+		msgs = append(msgs, fmt.Sprintf("row %d: %v", r.Index, r.Err))
+	}
+	return fmt.Sprintf("batch error, %d rows failed: %s", len(e.Rows), strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Rows))
+	for _, r := range e.Rows {
+		errs = append(errs, r.Err)
+	}
+	return errs
+}
+
+// BatchSizeOption 批量写入时每条语句的行数
+var BatchSizeOption = func(size int) OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Session(&gorm.Session{CreateBatchSize: size})
+	}
+}
+
+func (b *BaseDAO) CreateBatch(ctx context.Context, objs any, opts ...OptionFunc) error {
+	return b.writeBatch(ctx, "create", objs, opts, func(tx *gorm.DB, rows any) error {
+		return tx.Create(rows).Error
+	})
+}
+
+func (b *BaseDAO) UpsertBatch(ctx context.Context, objs any, opts ...OptionFunc) error {
+	return b.writeBatch(ctx, "upsert", objs, opts, func(tx *gorm.DB, rows any) error {
+		conflict, err := upsertClause(tx, rows)
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(conflict).Create(rows).Error
+	})
+}
+
+func (b *BaseDAO) DeleteWhere(ctx context.Context, q *api.QueryRequest, opts ...OptionFunc) (int64, error) {
+	// 与 api.DeleteRequest 一致，不允许不带任何条件删除全表
+	if object.IsEmpty(q.Query) && len(q.Expr) == 0 && (len(q.Fields) == 0 || len(q.Filters) == 0) {
+		return 0, fmt.Errorf("base dao delete where error: %w", EmptyConditionError)
+	}
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	result = result.Where(q.Query)
+	result = prepareFieldFilter(q, result)
+	result = prepareFilterExpr(q, result)
+	if !object.IsEmpty(q.Not) {
+		result.Not(q.Not)
+	}
+	// 按条件一条语句删除，不逐行处理关联
+	result = result.Delete(q.Query)
+	if result.Error != nil {
+		daoLogger(ctx).Errorw("base dao delete where error", "query", q, "error", result.Error)
+		return 0, fmt.Errorf("base dao delete where %v error: %w", q, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+/*
+writeBatch 在事务中分批写入，objs 为模型指针的切片
+写入前校验每一行，某一批失败时逐行重试找出失败的行，有任何失败则整体回滚并返回 BatchError
+*/
+func (b *BaseDAO) writeBatch(ctx context.Context, op string, objs any, opts []OptionFunc, write func(*gorm.DB, any) error) error {
+	rows := reflect.Indirect(reflect.ValueOf(objs))
+	if rows.Kind() != reflect.Slice {
+		return fmt.Errorf("base dao %s batch error, %T is not a slice", op, objs)
+	}
+	if rows.Len() == 0 {
+		return nil
+	}
+	if err := validateRows(rows); err != nil {
+		return err
+	}
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	size := result.CreateBatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	err := result.Transaction(func(tx *gorm.DB) error {
+		batchErr := &BatchError{}
+		for start := 0; start < rows.Len(); start += size {
+			end := min(start+size, rows.Len())
+			if err := tx.SavePoint(batchSavePoint).Error; err != nil {
+				return err
+			}
+			writeErr := write(tx, rows.Slice(start, end).Interface())
+			if writeErr == nil {
+				continue
+			}
+			if err := tx.RollbackTo(batchSavePoint).Error; err != nil {
+				return err
+			}
+			rowErrs, err := probeRows(tx, rows, start, end, write, writeErr)
+			if err != nil {
+				return err
+			}
+			batchErr.Rows = append(batchErr.Rows, rowErrs...)
+		}
+		if len(batchErr.Rows) > 0 {
+			return batchErr
+		}
+		return nil
+	})
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao batch error", "op", op, "rows", rows.Len(), "error", err)
+		return err
+	}
+	return nil
+}
+
+// probeRows 逐行写入找出失败的行，单行都成功时说明是批内数据互相冲突，报告整批
+func probeRows(tx *gorm.DB, rows reflect.Value, start, end int, write func(*gorm.DB, any) error, batchErr error) ([]RowError, error) {
+	var rowErrs []RowError
+	for i := start; i < end; i++ {
+		if err := tx.SavePoint(rowSavePoint).Error; err != nil {
+			return nil, err
+		}
+		writeErr := write(tx, rows.Slice(i, i+1).Interface())
+		if writeErr == nil {
+			continue
+		}
+		if err := tx.RollbackTo(rowSavePoint).Error; err != nil {
+			return nil, err
+		}
+		rowErrs = append(rowErrs, RowError{Index: i, Err: writeErr})
+	}
+	if len(rowErrs) == 0 {
+		for i := start; i < end; i++ {
+			rowErrs = append(rowErrs, RowError{Index: i, Err: batchErr})
+		}
+	}
+	return rowErrs, nil
+}
+
+func validateRows(rows reflect.Value) error {
+	batchErr := &BatchError{}
+	for i := 0; i < rows.Len(); i++ {
+		if v, ok := rows.Index(i).Interface().(api.Validator); ok {
+			if err := v.Validate(); err != nil {
+				batchErr.Rows = append(batchErr.Rows, RowError{Index: i, Err: err})
+			}
+		}
+	}
+	if len(batchErr.Rows) > 0 {
+		return batchErr
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+func newRoles(names ...string) []*domain.Role {
+	roles := make([]*domain.Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, &domain.Role{Name: name})
+	}
+	return roles
+}
+
+func roleNames(t *testing.T, d *BaseDAO) []string {
+	t.Helper()
+	var names []string
+	if err := d.conn.Model(&domain.Role{}).Order("name").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestCreateBatchRowErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  []string
+		opts  []OptionFunc
+		index []int
+	}{
+		{"single batch", []string{"a", "x", "b", "x"}, nil, []int{1, 3}},
+		// 第二批失败时第一批和第三批也回滚
+		{"other batches", []string{"a", "b", "c", "x", "d", "e"}, []OptionFunc{BatchSizeOption(2)}, []int{3}},
+		{"several batches", []string{"x", "a", "b", "c", "d", "x"}, []OptionFunc{BatchSizeOption(2)}, []int{0, 5}},
+		// 与同一批中前面的行冲突
+		{"in batch", []string{"a", "b", "a"}, nil, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := newSqliteDAO(t)
+			ctx := context.Background()
+			if err := d.Create(ctx, &domain.Role{Name: "x"}); err != nil {
+				t.Fatal(err)
+			}
+			err := d.CreateBatch(ctx, newRoles(tt.rows...), tt.opts...)
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("create batch error = %v, want BatchError", err)
+			}
+			index := make([]int, 0, len(batchErr.Rows))
+			for _, r := range batchErr.Rows {
+				if r.Err == nil {
+					t.Fatalf("row %d error is nil", r.Index)
+				}
+				index = append(index, r.Index)
+			}
+			if !reflect.DeepEqual(index, tt.index) {
+				t.Fatalf("failed rows = %v, want %v", index, tt.index)
+			}
+			if names := roleNames(t, d); !reflect.DeepEqual(names, []string{"x"}) {
+				t.Fatalf("roles after failed batch = %v, want rolled back", names)
+			}
+		})
+	}
+}
+
+// TestBatchSize 按 BatchSizeOption 分批，每批一条插入语句
+func TestBatchSize(t *testing.T) {
+	tests := []struct {
+		rows    int
+		opts    []OptionFunc
+		inserts int
+	}{
+		{5, []OptionFunc{BatchSizeOption(2)}, 3},
+		{4, []OptionFunc{BatchSizeOption(2)}, 2},
+		{5, []OptionFunc{BatchSizeOption(10)}, 1},
+		{5, nil, 1},
+	}
+	for _, tt := range tests {
+		d, recorder := newSqliteDAO(t)
+		names := make([]string, 0, tt.rows)
+		for i := 0; i < tt.rows; i++ {
+			names = append(names, string(rune('a'+i)))
+		}
+		if err := d.CreateBatch(context.Background(), newRoles(names...), tt.opts...); err != nil {
+			t.Fatal(err)
+		}
+		inserts := 0
+		for _, sql := range recorder.statements() {
+			if strings.HasPrefix(sql, "INSERT INTO `roles`") {
+				inserts++
+			}
+		}
+		if inserts != tt.inserts {
+			t.Fatalf("%d rows with %d options inserted by %d statements, want %d", tt.rows, len(tt.opts), inserts, tt.inserts)
+		}
+		if got := roleNames(t, d); !reflect.DeepEqual(got, names) {
+			t.Fatalf("roles = %v, want %v", got, names)
+		}
+	}
+}
+
+func TestCreateBatchEmpty(t *testing.T) {
+	d, recorder := newSqliteDAO(t)
+	if err := d.CreateBatch(context.Background(), []*domain.Role{}); err != nil {
+		t.Fatal(err)
+	}
+	if sqls := recorder.statements(); len(sqls) != 0 {
+		t.Fatalf("empty batch executed %v", sqls)
+	}
+	if err := d.CreateBatch(context.Background(), &domain.Role{Name: "a"}); err == nil {
+		t.Fatal("create batch of a single object should fail")
+	}
+}
+
+// TestUpsertBatchUniqueIndex 按唯一索引而不是主键判断冲突，冲突时更新原来的行
+func TestUpsertBatchUniqueIndex(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	existing := &domain.Role{Name: "a"}
+	if err := d.Create(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+	permissions := []domain.Permission{{Resource: "template", Verbs: []string{"GET"}}}
+	rows := []*domain.Role{{Name: "a", Permissions: permissions}, {Name: "b"}}
+	if err := d.UpsertBatch(ctx, rows, BatchSizeOption(1)); err != nil {
+		t.Fatal(err)
+	}
+	if names := roleNames(t, d); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("roles = %v, want [a b]", names)
+	}
+	found := &domain.Role{Name: "a"}
+	if err := d.Get(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != existing.ID {
+		t.Fatalf("upserted id = %d, want %d", found.ID, existing.ID)
+	}
+	if !reflect.DeepEqual(found.Permissions, permissions) {
+		t.Fatalf("upserted permissions = %+v, want %+v", found.Permissions, permissions)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.CreateBatch(ctx, newRoles("a", "b", "c")); err != nil {
+		t.Fatal(err)
+	}
+	empty := map[string]*api.QueryRequest{
+		"no condition":     {Query: &domain.Role{}},
+		"fields only":      {Query: &domain.Role{}, Filter: api.Filter{Fields: "name"}},
+		"filters only":     {Query: &domain.Role{}, Filter: api.Filter{Filters: "a"}},
+		"not only":         {Query: &domain.Role{}, Not: &domain.Role{Name: "a"}},
+		"blank expression": {Query: &domain.Role{}, Filter: api.Filter{Expr: ""}},
+	}
+	for name, q := range empty {
+		t.Run(name, func(t *testing.T) {
+			if affected, err := d.DeleteWhere(ctx, q); !errors.Is(err, EmptyConditionError) {
+				t.Fatalf("delete where = %d, %v, want %v", affected, err, EmptyConditionError)
+			}
+		})
+	}
+	if names := roleNames(t, d); len(names) != 3 {
+		t.Fatalf("roles after empty delete = %v", names)
+	}
+
+	q := &api.QueryRequest{Query: &domain.Role{}, Filter: api.Filter{Expr: "name in ('a', 'b')"}}
+	if affected, err := d.DeleteWhere(ctx, q); err != nil || affected != 2 {
+		t.Fatalf("delete where = %d, %v, want 2", affected, err)
+	}
+	// 已软删除的行不计入删除的行数
+	if affected, err := d.DeleteWhere(ctx, q); err != nil || affected != 0 {
+		t.Fatalf("delete where again = %d, %v, want 0", affected, err)
+	}
+	if names := roleNames(t, d); !reflect.DeepEqual(names, []string{"c"}) {
+		t.Fatalf("roles after delete where = %v, want [c]", names)
+	}
+}
+
+// TestDeleteWhereNoCascade 与 Delete 不同，不级联删除关联
+func TestDeleteWhereNoCascade(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.conn.AutoMigrate(&cascadeParent{}, &cascadeChild{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(ctx, &cascadeParent{Name: "parent", Children: []cascadeChild{{Name: "child"}}}); err != nil {
+		t.Fatal(err)
+	}
+	affected, err := d.DeleteWhere(ctx, &api.QueryRequest{Query: &cascadeParent{Name: "parent"}})
+	if err != nil || affected != 1 {
+		t.Fatalf("delete where = %d, %v, want 1", affected, err)
+	}
+	if err = d.Get(ctx, &cascadeChild{Name: "child"}); err != nil {
+		t.Fatalf("child should not be deleted, get error = %v", err)
+	}
+}
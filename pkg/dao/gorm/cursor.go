@@ -0,0 +1,125 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"sync"
+)
+
+var schemaCache = &sync.Map{}
+
+func parseSchema(db *gorm.DB, model any) (*schema.Schema, error) {
+	return schema.Parse(model, schemaCache, db.NamingStrategy)
+}
+
+// prepareCursor 游标分页，从游标中记录的最后一行之后开始查询
+func prepareCursor(q *api.QueryRequest, keys []api.SortKey, fields map[string]any, result *gorm.DB) *gorm.DB {
+	if len(q.Cursor) == 0 {
+		return result
+	}
+	values, err := cursorValues(q, keys, result)
+	if err != nil {
+		_ = result.AddError(err)
+		return result
+	}
+	// (k1 在v1之后) or (k1 = v1 and k2 在v2之后) or ...
+	ors := make([]clause.Expression, 0, len(keys))
+	equals := make([]clause.Expression, 0, len(keys))
+	for i, key := range keys {
+		column, err := gormColumn(fields, key.Field)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
+		}
+		if after := afterValue(key, column, values[i]); after != nil {
+			ors = append(ors, clause.And(append(append([]clause.Expression{}, equals...), after)...))
+		}
+		// 值为nil时为 IS NULL
+		equals = append(equals, clause.Eq{Column: column, Value: values[i]})
+	}
+	if len(ors) == 0 {
+		return result.Where(clause.Expr{SQL: "1 = 0"})
+	}
+	return result.Where(clause.Or(ors...))
+}
+
+// afterValue 排序在 value 之后的条件，没有时返回nil
+func afterValue(key api.SortKey, column clause.Column, value any) clause.Expression {
+	if value == nil {
+		if nullsFirst(key) {
+			return clause.Neq{Column: column, Value: nil}
+		}
+		return nil
+	}
+	var after clause.Expression = clause.Gt{Column: column, Value: value}
+	if key.Desc {
+		after = clause.Lt{Column: column, Value: value}
+	}
+	if !nullsFirst(key) {
+		return clause.Or(after, clause.Eq{Column: column, Value: nil})
+	}
+	return after
+}
+
+// cursorValues 按模型字段类型解析游标中的值
+func cursorValues(q *api.QueryRequest, keys []api.SortKey, db *gorm.DB) ([]any, error) {
+	raws, err := api.DecodeCursor(q.Cursor, keys)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSchema(db, q.Query)
+	if err != nil {
+		return nil, fmt.Errorf("cursor error, parse model: %w", err)
+	}
+	values := make([]any, 0, len(keys))
+	for i, key := range keys {
+		field := s.LookUpField(key.Field)
+		if field == nil {
+			return nil, fmt.Errorf("cursor error, invalid field %s", key.Field)
+		}
+		if bytes.Equal(raws[i], []byte("null")) {
+			values = append(values, nil)
+			continue
+		}
+		v := reflect.New(field.FieldType)
+		if err = json.Unmarshal(raws[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("cursor error, invalid value of %s: %w", key.Field, api.InvalidCursorError)
+		}
+		values = append(values, v.Elem().Interface())
+	}
+	return values, nil
+}
+
+// nextCursor 满页时根据最后一行生成下一页游标
+// 排序列可能为 NULL 时模型字段需要为指针或 sql.Null 类型，否则空值按零值写入游标
+func nextCursor(ctx context.Context, q *api.QueryRequest, keys []api.SortKey, results any, db *gorm.DB) (string, error) {
+	rows := reflect.Indirect(reflect.ValueOf(results))
+	if q.PageSize <= 0 || rows.Kind() != reflect.Slice || rows.Len() < q.PageSize || len(keys) == 0 {
+		return "", nil
+	}
+	s, err := parseSchema(db, q.Query)
+	if err != nil {
+		return "", fmt.Errorf("cursor error, parse model: %w", err)
+	}
+	last := rows.Index(rows.Len() - 1)
+	for last.Kind() == reflect.Pointer {
+		last = last.Elem()
+	}
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		field := s.LookUpField(key.Field)
+		if field == nil {
+			return "", fmt.Errorf("cursor error, invalid field %s", key.Field)
+		}
+		v, _ := field.ValueOf(ctx, last)
+		values = append(values, v)
+	}
+	return api.EncodeCursor(keys, values)
+}
@@ -0,0 +1,140 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+// cursorItem 排序列可以为空
+type cursorItem struct {
+	domain.Model
+	Priority *int   `json:"priority" gorm:"column:priority"`
+	Name     string `json:"name" gorm:"column:name"`
+}
+
+func newCursorDAO(t *testing.T) *BaseDAO {
+	t.Helper()
+	domain.RegisterModel(cursorItem{})
+	d, _ := newSqliteDAO(t)
+	if err := d.conn.AutoMigrate(&cursorItem{}); err != nil {
+		t.Fatal(err)
+	}
+	priority := func(p int) *int { return &p }
+	items := []*cursorItem{
+		{Priority: nil, Name: "a"},
+		{Priority: priority(3), Name: "b"},
+		{Priority: nil, Name: "c"},
+		{Priority: priority(1), Name: "d"},
+		{Priority: priority(3), Name: "e"},
+		{Priority: nil, Name: "f"},
+		{Priority: priority(2), Name: "g"},
+	}
+	if err := d.CreateBatch(context.Background(), items); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func cursorQuery(sort string, size int, cursor string) *api.QueryRequest {
+	return &api.QueryRequest{
+		Query:      &cursorItem{},
+		Pagination: api.Pagination{Page: 1, PageSize: size, Cursor: cursor},
+		Sorting:    api.Sorting{Sort: sort},
+	}
+}
+
+func itemNames(items []cursorItem) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// TestCursorNulls 排序列有空值时逐页查询的结果与一次查询相同，不重复也不遗漏
+func TestCursorNulls(t *testing.T) {
+	d := newCursorDAO(t)
+	ctx := context.Background()
+	sorts := []string{
+		"priority", "-priority", "priority:nulls_last", "-priority:nulls_first",
+		"priority,-name", "-priority:nulls_first,name", "-name",
+	}
+	for _, sort := range sorts {
+		var all []cursorItem
+		if err := d.List(ctx, cursorQuery(sort, 0, ""), &all); err != nil {
+			t.Fatal(err)
+		}
+		want := itemNames(all)
+		if len(want) != 7 {
+			t.Fatalf("sort %s list = %v, want 7 rows", sort, want)
+		}
+		for _, size := range []int{1, 2, 3, 7} {
+			t.Run(fmt.Sprintf("%s by %d", sort, size), func(t *testing.T) {
+				var got []string
+				cursor := ""
+				for page := 0; ; page++ {
+					if page > len(want) {
+						t.Fatalf("too many pages, got %v", got)
+					}
+					q := cursorQuery(sort, size, cursor)
+					var items []cursorItem
+					if err := d.List(ctx, q, &items); err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, itemNames(items)...)
+					if len(q.NextCursor) == 0 {
+						break
+					}
+					cursor = q.NextCursor
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("pages = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	api.SetCursorSecret("test secret")
+	d := newCursorDAO(t)
+	ctx := context.Background()
+	q := cursorQuery("priority", 2, "")
+	var items []cursorItem
+	if err := d.List(ctx, q, &items); err != nil {
+		t.Fatal(err)
+	}
+	cursor := q.NextCursor
+	if len(cursor) == 0 {
+		t.Fatal("full page should return next cursor")
+	}
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"tampered", "priority", cursor[:len(cursor)-2] + "xx"},
+		{"different sort", "-priority", cursor},
+		{"different sort_by", "name", cursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := d.List(ctx, cursorQuery(tt.sort, 2, tt.cursor), &items); !errors.Is(err, api.InvalidCursorError) {
+				t.Fatalf("list with cursor error = %v, want %v", err, api.InvalidCursorError)
+			}
+		})
+	}
+	t.Run("wrong secret", func(t *testing.T) {
+		api.SetCursorSecret("other secret")
+		t.Cleanup(func() { api.SetCursorSecret("test secret") })
+		if err := d.List(ctx, cursorQuery("priority", 2, cursor), &items); !errors.Is(err, api.InvalidCursorError) {
+			t.Fatalf("list with cursor error = %v, want %v", err, api.InvalidCursorError)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package gorm
+
+import (
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gorm 方言名，与 Dialector.Name() 一致
+const (
+	dialectMysql    = "mysql"
+	dialectSqlite   = "sqlite"
+	dialectPostgres = "postgres"
+)
+
+// dialectOf 构造语句时的方言
+func dialectOf(builder clause.Builder) string {
+	if stmt, ok := builder.(*gorm.Statement); ok && stmt.DB != nil && stmt.Dialector != nil {
+		return stmt.Dialector.Name()
+	}
+	return dialectMysql
+}
+
+// iLike 不区分大小写的 LIKE，MySQL、SQLite 的 LIKE 默认不区分大小写，PostgreSQL 使用 ILIKE
+type iLike clause.Like
+
+func (l iLike) Build(builder clause.Builder) {
+	l.build(builder, false)
+}
+
+func (l iLike) NegationBuild(builder clause.Builder) {
+	l.build(builder, true)
+}
+
+func (l iLike) build(builder clause.Builder, not bool) {
+	op := " LIKE "
+	if dialectOf(builder) == dialectPostgres {
+		op = " ILIKE "
+	}
+	if not {
+		op = " NOT" + op
+	}
+	builder.WriteQuoted(l.Column)
+	builder.WriteString(op)
+	builder.AddVar(builder, l.Value)
+}
+
+// 各方言的时间分桶格式，周为 ISO 周（SQLite 不支持，为周一开始的年内周数）
+var bucketFormats = map[string]map[string]string{
+	dialectMysql: {
+		api.BucketHour:  "%Y-%m-%d %H:00:00",
+		api.BucketDay:   "%Y-%m-%d",
+		api.BucketWeek:  "%x-%v",
+		api.BucketMonth: "%Y-%m",
+		api.BucketYear:  "%Y",
+	},
+	dialectSqlite: {
+		api.BucketHour:  "%Y-%m-%d %H:00:00",
+		api.BucketDay:   "%Y-%m-%d",
+		api.BucketWeek:  "%Y-%W",
+		api.BucketMonth: "%Y-%m",
+		api.BucketYear:  "%Y",
+	},
+	dialectPostgres: {
+		api.BucketHour:  "YYYY-MM-DD HH24:00:00",
+		api.BucketDay:   "YYYY-MM-DD",
+		api.BucketWeek:  "IYYY-IW",
+		api.BucketMonth: "YYYY-MM",
+		api.BucketYear:  "YYYY",
+	},
+}
+
+// timeBucket 按时间粒度格式化列，结果为字符串
+type timeBucket struct {
+	Column clause.Column
+	Bucket string
+}
+
+func (t timeBucket) Build(builder clause.Builder) {
+	dialect := dialectOf(builder)
+	formats, ok := bucketFormats[dialect]
+	if !ok {
+		formats = bucketFormats[dialectMysql]
+	}
+	switch dialect {
+	case dialectSqlite:
+		builder.WriteString("strftime(")
+		builder.AddVar(builder, formats[t.Bucket])
+		builder.WriteString(", ")
+		builder.WriteQuoted(t.Column)
+	case dialectPostgres:
+		builder.WriteString("to_char(")
+		builder.WriteQuoted(t.Column)
+		builder.WriteString(", ")
+		builder.AddVar(builder, formats[t.Bucket])
+	default:
+		builder.WriteString("DATE_FORMAT(")
+		builder.WriteQuoted(t.Column)
+		builder.WriteString(", ")
+		builder.AddVar(builder, formats[t.Bucket])
+	}
+	builder.WriteString(")")
+}
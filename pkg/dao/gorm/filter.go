@@ -0,0 +1,101 @@
+package gorm
+
+import (
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+)
+
+// prepareFilterExpr 解析过滤表达式并转换为gorm条件，字段必须是模型的gorm列
+func prepareFilterExpr(q *api.QueryRequest, result *gorm.DB) *gorm.DB {
+	expr, err := api.ParseFilter(q.Expr)
+	if err != nil {
+		_ = result.AddError(err)
+		return result
+	}
+	if expr == nil {
+		return result
+	}
+	fields := domain.GetGormFields(object.ClassName(q.Query))
+	cond, err := buildFilterClause(expr, fields)
+	if err != nil {
+		_ = result.AddError(err)
+		return result
+	}
+	return result.Where(cond)
+}
+
+// gormColumn 列名必须在模型的gorm列中，由gorm按标识符转义，不接受带表名前缀等其他写法
+func gormColumn(fields map[string]any, name string) (clause.Column, error) {
+	name = strings.TrimSpace(name)
+	if _, ok := fields[name]; !ok {
+		return clause.Column{}, fmt.Errorf("query option error, invalid field %q", name)
+	}
+	// 带上当前表名，关联查询时避免列名冲突
+	return clause.Column{Table: clause.CurrentTable, Name: name}, nil
+}
+
+func buildFilterClause(expr api.FilterExpr, fields map[string]any) (clause.Expression, error) {
+	switch e := expr.(type) {
+	case *api.FilterLogic:
+		exprs := make([]clause.Expression, 0, len(e.Exprs))
+		for _, sub := range e.Exprs {
+			c, err := buildFilterClause(sub, fields)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, c)
+		}
+		if e.Op == api.FilterOr {
+			return clause.Or(exprs...), nil
+		}
+		return clause.And(exprs...), nil
+	case *api.FilterNot:
+		c, err := buildFilterClause(e.Expr, fields)
+		if err != nil {
+			return nil, err
+		}
+		return clause.Not(c), nil
+	case *api.FilterCondition:
+		return buildFilterCondition(e, fields)
+	default:
+		return nil, fmt.Errorf("filter error, unsupported expression %T", expr)
+	}
+}
+
+func buildFilterCondition(cond *api.FilterCondition, fields map[string]any) (clause.Expression, error) {
+	column, err := gormColumn(fields, cond.Field)
+	if err != nil {
+		return nil, err
+	}
+	switch cond.Op {
+	case api.FilterEq:
+		return clause.Eq{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterNe:
+		return clause.Neq{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterGt:
+		return clause.Gt{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterGe:
+		return clause.Gte{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterLt:
+		return clause.Lt{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterLe:
+		return clause.Lte{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterLike:
+		return iLike{Column: column, Value: cond.Values[0]}, nil
+	case api.FilterIn:
+		return clause.IN{Column: column, Values: cond.Values}, nil
+	case api.FilterBetween:
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{column, cond.Values[0], cond.Values[1]}}, nil
+	case api.FilterIsNull:
+		return clause.Eq{Column: column, Value: nil}, nil
+	case api.FilterNotNull:
+		return clause.Neq{Column: column, Value: nil}, nil
+	default:
+		return nil, fmt.Errorf("filter error, unsupported operator %s", cond.Op)
+	}
+}
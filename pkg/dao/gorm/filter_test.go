@@ -0,0 +1,107 @@
+package gorm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"gorm.io/gorm/clause"
+)
+
+// 不是模型gorm列的名称，包括注入、带表名、已转义的列名和只存在于json中的字段
+var invalidColumns = []string{
+	"id; drop table x",
+	"x.id",
+	"roles.name",
+	"`name`",
+	`"name"`,
+	"name)",
+	"unknown",
+	// 只在json中出现，不是可查询的gorm列
+	"deleted_at",
+	// 结构体字段名
+	"Name",
+}
+
+func TestGormColumn(t *testing.T) {
+	fields := domain.GetGormFields(object.ClassName(domain.Role{}))
+	for _, name := range []string{"id", "name", " name ", "permissions", "created_at"} {
+		column, err := gormColumn(fields, name)
+		if err != nil {
+			t.Errorf("gormColumn(%q) error = %v", name, err)
+			continue
+		}
+		if column.Table != clause.CurrentTable || column.Name != strings.TrimSpace(name) {
+			t.Errorf("gormColumn(%q) = %+v", name, column)
+		}
+	}
+	for _, name := range invalidColumns {
+		if column, err := gormColumn(fields, name); err == nil {
+			t.Errorf("gormColumn(%q) = %+v, want error", name, column)
+		}
+	}
+}
+
+// TestQueryColumns Fields、Filter、SortBy 等请求参数中的列名在 DAO 中校验，非法时不执行查询
+func TestQueryColumns(t *testing.T) {
+	requests := map[string]func(name string) *api.QueryRequest{
+		"fields": func(name string) *api.QueryRequest {
+			return &api.QueryRequest{Filter: api.Filter{Fields: "name," + name, Filters: "a"}}
+		},
+		"filter": func(name string) *api.QueryRequest {
+			return &api.QueryRequest{Filter: api.Filter{Expr: name + " eq 'a'"}}
+		},
+		"sort_by": func(name string) *api.QueryRequest {
+			return &api.QueryRequest{Sorting: api.Sorting{SortBy: name}}
+		},
+		"sort": func(name string) *api.QueryRequest {
+			return &api.QueryRequest{Sorting: api.Sorting{Sort: "-" + name + ":nulls_last"}}
+		},
+		"select": func(name string) *api.QueryRequest {
+			return &api.QueryRequest{Fieldset: api.Fieldset{Select: name}}
+		},
+	}
+	valid := map[string]string{
+		"fields":  "(`roles`.`name` LIKE \"%a%\" OR `roles`.`permissions` LIKE \"%a%\")",
+		"filter":  "`roles`.`permissions` = \"a\"",
+		"sort_by": "ORDER BY `roles`.`permissions` NULLS FIRST",
+		"sort":    "ORDER BY `roles`.`permissions` DESC NULLS LAST",
+		"select":  "SELECT `roles`.`id`,`roles`.`permissions`",
+	}
+	for param, request := range requests {
+		t.Run(param+" valid", func(t *testing.T) {
+			d, recorder := newDryRunDAO(t, nil)
+			q := request("permissions")
+			q.Query = &domain.Role{}
+			var roles []domain.Role
+			if err := d.List(context.Background(), q, &roles); err != nil {
+				t.Fatal(err)
+			}
+			assertSQL(t, lastSQL(t, recorder, "SELECT"), []string{valid[param]}, nil)
+		})
+		for _, name := range invalidColumns {
+			t.Run(param+" "+name, func(t *testing.T) {
+				d, recorder := newDryRunDAO(t, nil)
+				q := request(name)
+				q.Query = &domain.Role{}
+				var roles []domain.Role
+				if err := d.List(context.Background(), q, &roles); err == nil {
+					t.Fatalf("list with %s %q should fail, sql %q", param, name, recorder.statements())
+				}
+				if sqls := recorder.statements(); len(sqls) > 0 {
+					t.Fatalf("list with %s %q should not query, sql %q", param, name, sqls)
+				}
+				// Count 只使用过滤条件
+				if param != "fields" && param != "filter" {
+					return
+				}
+				if _, err := d.Count(context.Background(), q); err == nil {
+					t.Fatalf("count with %s %q should fail", param, name)
+				}
+			})
+		}
+	}
+}
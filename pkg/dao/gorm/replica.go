@@ -0,0 +1,212 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"gorm.io/gorm"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// replicaReadKey 为 true 时查询可以路由到副本，见 PrimaryOption
+	replicaReadKey  = "aqua:replica_read"
+	replicaCallback = "aqua:replica"
+	// 单个副本健康检查的最长时间，检查间隔更短时为检查间隔
+	replicaCheckTimeout = 3 * time.Second
+)
+
+// PrimaryOption 强制从主库读取，用于写后立即读等不能容忍复制延迟的场景
+var PrimaryOption = func() OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(replicaReadKey, false)
+	}
+}
+
+// replica 只读副本，healthy 由后台健康检查更新
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	timeout  time.Duration
+	stop     chan struct{}
+}
+
+// UseReplicas 读操作（Count、Aggregate、List、Get 等）路由到健康的副本，写操作和事务总是使用主库
+// 每隔 interval 在后台检查副本，无法连接或复制延迟超过 maxLag 的副本不再读取，没有可用副本时读主库
+func (b *BaseDAO) UseReplicas(interval, maxLag time.Duration, replicas ...*gorm.DB) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+	if b.replicas != nil {
+		return errors.New("base dao replicas error, replicas already set")
+	}
+	set := &replicaSet{maxLag: maxLag, stop: make(chan struct{})}
+	for i, db := range replicas {
+		set.replicas = append(set.replicas, &replica{name: "replica-" + strconv.Itoa(i), db: db})
+	}
+	set.timeout = min(interval, replicaCheckTimeout)
+	if err := b.conn.Callback().Query().Before("gorm:query").Register(replicaCallback, set.route); err != nil {
+		return fmt.Errorf("base dao replicas error: %w", err)
+	}
+	if err := b.conn.Callback().Row().Before("gorm:row").Register(replicaCallback, set.route); err != nil {
+		return fmt.Errorf("base dao replicas error: %w", err)
+	}
+	b.replicas = set
+	// 第一次检查也在后台进行，不阻塞启动，检查通过前读主库
+	go set.run(interval)
+	return nil
+}
+
+// reader 读操作使用的连接，查询时可以路由到副本
+func (b *BaseDAO) reader(ctx context.Context) *gorm.DB {
+	return b.db(ctx).Set(replicaReadKey, true)
+}
+
+// route 在执行查询前替换连接，事务、加锁的查询和 PrimaryOption 仍使用主库
+func (s *replicaSet) route(db *gorm.DB) {
+	if read, ok := db.Get(replicaReadKey); !ok || read != true {
+		return
+	}
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		return
+	}
+	if r := s.pick(); r != nil {
+		db.Statement.ConnPool = r.db.Statement.ConnPool
+	}
+}
+
+// pick 轮询健康的副本
+func (s *replicaSet) pick() *replica {
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *replicaSet) run(interval time.Duration) {
+	s.check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+func (s *replicaSet) check() {
+	for _, r := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		lag, err := replicaLag(ctx, r.db)
+		cancel()
+		healthy := err == nil && lag <= s.maxLag
+		if healthy != r.healthy.Swap(healthy) {
+			if healthy {
+				log.Named(LoggerName).Infow("base dao replica available", "replica", r.name, "lag", lag)
+			} else {
+				log.Named(LoggerName).Warnw("base dao replica unavailable", "replica", r.name, "lag", lag, "error", err)
+			}
+		}
+	}
+}
+
+func (s *replicaSet) close() error {
+	close(s.stop)
+	var errs []error
+	for _, r := range s.replicas {
+		sqlDB, err := r.db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// replicaLag 副本的复制延迟，不是副本时为0
+func replicaLag(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+	if err = sqlDB.PingContext(ctx); err != nil {
+		return 0, err
+	}
+	switch db.Dialector.Name() {
+	case dialectMysql:
+		return mysqlReplicaLag(ctx, sqlDB)
+	case dialectPostgres:
+		// 没有待回放的 WAL 时主库可能只是没有写入，视为没有延迟
+		var seconds sql.NullFloat64
+		err = sqlDB.QueryRowContext(ctx, `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`).Scan(&seconds)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	default:
+		return 0, nil
+	}
+}
+
+// mysqlReplicaLag 8.0.22 之前只支持 SHOW SLAVE STATUS
+func mysqlReplicaLag(ctx context.Context, sqlDB *sql.DB) (time.Duration, error) {
+	rows, err := sqlDB.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = sqlDB.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		// 复制线程停止时为 NULL
+		if values[i] == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replication lag is unknown")
+}
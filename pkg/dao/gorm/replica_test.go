@@ -0,0 +1,152 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type versionedTemplate struct {
+	domain.Model
+	domain.Versioning
+	Name string `json:"name" gorm:"column:name"`
+}
+
+// newReplicaDAO 主库和副本是两个独立的内存数据库，用数据的差异判断读取的是哪个库
+func newReplicaDAO(t *testing.T) (*BaseDAO, *BaseDAO) {
+	t.Helper()
+	primary, _ := newSqliteDAO(t)
+	replica, _ := newSqliteDAO(t)
+	for _, d := range []*BaseDAO{primary, replica} {
+		if err := d.conn.AutoMigrate(&versionedTemplate{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := primary.UseReplicas(time.Hour, time.Second, replica.conn); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { close(primary.replicas.stop) })
+	waitReplicas(t, primary, true)
+	return primary, replica
+}
+
+func waitReplicas(t *testing.T, d *BaseDAO, healthy bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, r := range d.replicas.replicas {
+		for r.healthy.Load() != healthy {
+			if time.Now().After(deadline) {
+				t.Fatalf("replica %s healthy should be %v", r.name, healthy)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// TestUseReplicasNonBlocking 副本无法连接时也不阻塞启动，检查通过前读主库
+func TestUseReplicasNonBlocking(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	// 不可路由的地址，连接会一直等待到超时
+	unreachable, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "u:p@tcp(10.255.255.1:3306)/aqua?timeout=1m",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err = d.UseReplicas(time.Hour, time.Second, unreachable); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("UseReplicas blocked for %v", elapsed)
+	}
+	t.Cleanup(func() { _ = d.replicas.close() })
+	if d.replicas.timeout != replicaCheckTimeout {
+		t.Fatalf("replica check timeout = %v, want %v", d.replicas.timeout, replicaCheckTimeout)
+	}
+	if err = d.Create(context.Background(), &domain.Template{Name: "primary"}); err != nil {
+		t.Fatal(err)
+	}
+	var templates []domain.Template
+	if err = d.List(context.Background(), &api.QueryRequest{Query: &domain.Template{}}, &templates); err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 {
+		t.Fatalf("list before replica check = %d rows, want 1 from primary", len(templates))
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary, replica := newReplicaDAO(t)
+	ctx := context.Background()
+	if err := replica.Create(ctx, &domain.Template{Name: "replica"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts []OptionFunc
+		want string
+	}{
+		{"replica", nil, "replica"},
+		{"primary", []OptionFunc{PrimaryOption()}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := func(templates []domain.Template) string {
+				if len(templates) == 0 {
+					return ""
+				}
+				return templates[0].Name
+			}
+			var listed []domain.Template
+			if err := primary.List(ctx, &api.QueryRequest{Query: &domain.Template{}}, &listed, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := name(listed); got != tt.want {
+				t.Fatalf("list = %q, want %q", got, tt.want)
+			}
+			var inClause []domain.Template
+			err := primary.ListWithInClause(ctx, &inClause, "name IN ?", [][]any{{"replica"}}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := name(inClause); got != tt.want {
+				t.Fatalf("list with in clause = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVersionReadsPrimary 未指定版本时以主库的当前版本做乐观锁，不读延迟的副本
+func TestVersionReadsPrimary(t *testing.T) {
+	primary, replica := newReplicaDAO(t)
+	ctx := context.Background()
+	for _, d := range []*BaseDAO{primary, replica} {
+		if err := d.Create(ctx, &versionedTemplate{Name: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 主库已更新到版本2，副本仍为版本1
+	if err := primary.Update(ctx, &versionedTemplate{Model: domain.Model{ID: 1}, Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	updated := &versionedTemplate{Model: domain.Model{ID: 1}, Name: "c"}
+	if err := primary.Update(ctx, updated); err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			t.Fatal("version should be read from primary")
+		}
+		t.Fatal(err)
+	}
+	if updated.Version != 3 {
+		t.Fatalf("version = %d, want 3", updated.Version)
+	}
+}
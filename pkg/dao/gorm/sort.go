@@ -0,0 +1,92 @@
+package gorm
+
+import (
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+)
+
+// 主键列，作为最后的排序列保证分页稳定
+const primaryKeyColumn = "id"
+
+// prepareSorting 多列排序，列名只能来自模型的gorm列
+func prepareSorting(keys []api.SortKey, fields map[string]any, result *gorm.DB) *gorm.DB {
+	if len(keys) == 0 {
+		return result
+	}
+	sql := make([]string, 0, len(keys))
+	vars := make([]any, 0, len(keys))
+	mysql := result.Dialector.Name() == dialectMysql
+	for _, key := range keys {
+		column, err := gormColumn(fields, key.Field)
+		if err != nil {
+			_ = result.AddError(err)
+			return result
+		}
+		order := "?"
+		if key.Desc {
+			order = "? DESC"
+		}
+		if !mysql {
+			// 其他数据库的空值顺序不同（PostgreSQL 升序时空值在后），总是显式指定，与游标分页保持一致
+			if nullsFirst(key) {
+				order += " NULLS FIRST"
+			} else {
+				order += " NULLS LAST"
+			}
+			sql = append(sql, order)
+			vars = append(vars, column)
+			continue
+		}
+		// MySQL 不支持 NULLS FIRST/LAST，升序时空值在前、降序时在后，先按是否为空排序来模拟
+		switch key.Nulls {
+		case api.NullsFirst:
+			sql = append(sql, "? IS NULL DESC")
+			vars = append(vars, column)
+		case api.NullsLast:
+			sql = append(sql, "? IS NULL")
+			vars = append(vars, column)
+		}
+		sql = append(sql, order)
+		vars = append(vars, column)
+	}
+	return result.Order(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(sql, ", "), Vars: vars, WithoutParentheses: true}})
+}
+
+// sortKeys 实际使用的排序列，未指定时使用模型的默认排序，最后总是按主键排序
+func sortKeys(q *api.QueryRequest, fields map[string]any) ([]api.SortKey, error) {
+	keys, err := q.Sorting.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		if s, ok := q.Query.(domain.DefaultSorting); ok {
+			if keys, err = api.ParseSort(s.DefaultSort()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, ok := fields[primaryKeyColumn]; ok && !hasSortKey(keys, primaryKeyColumn) {
+		keys = append(keys, api.SortKey{Field: primaryKeyColumn})
+	}
+	return keys, nil
+}
+
+func hasSortKey(keys []api.SortKey, field string) bool {
+	for _, key := range keys {
+		if key.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// nullsFirst 空值是否排在前面，未指定时与MySQL默认行为一致，其他数据库排序时显式指定
+func nullsFirst(key api.SortKey) bool {
+	if len(key.Nulls) > 0 {
+		return key.Nulls == api.NullsFirst
+	}
+	return !key.Desc
+}
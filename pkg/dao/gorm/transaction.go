@@ -0,0 +1,119 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// DefaultTxRetries 死锁、锁等待超时时事务的最大重试次数
+	DefaultTxRetries = 3
+	// txRetryBackoff 第一次重试的等待时间，之后每次翻倍
+	txRetryBackoff = 50 * time.Millisecond
+
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+	sqliteBusy           = 5
+	sqliteLocked         = 6
+)
+
+// postgresRetryStates 可重试的 PostgreSQL SQLSTATE：串行化失败、死锁、获取锁失败
+var postgresRetryStates = map[string]struct{}{
+	"40001": {},
+	"40P01": {},
+	"55P03": {},
+}
+
+// PanicError 事务函数 panic，事务已回滚
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("transaction panic: %v", e.Value)
+}
+
+type txKey struct{}
+
+// withTx 在 context 中保存事务，同一 context 上的 DAO 操作和嵌套的 Transaction 复用该事务
+func withTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func txFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// db 返回 context 中的事务，不在事务中时返回当前连接
+func (b *BaseDAO) db(ctx context.Context) *gorm.DB {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return b.conn.WithContext(ctx)
+}
+
+func (b *BaseDAO) Transaction(ctx context.Context, fn func(ctx context.Context, tx DAO) error) error {
+	// 嵌套事务使用 savepoint，死锁时整个事务已被回滚，只在最外层重试
+	if tx, ok := txFromContext(ctx); ok {
+		return b.transaction(ctx, tx, fn)
+	}
+	for attempt := 0; ; attempt++ {
+		err := b.transaction(ctx, b.conn, fn)
+		if err == nil || attempt >= DefaultTxRetries || !retryableTxError(b.conn.Dialector.Name(), err) {
+			return err
+		}
+		backoff := txRetryBackoff << attempt
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+		daoLogger(ctx).Warnw("base dao transaction retry", "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (b *BaseDAO) transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context, tx DAO) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+				daoLogger(ctx).Errorw("base dao transaction panic", "panic", r, "stack", string(err.(*PanicError).Stack))
+			}
+		}()
+		return fn(withTx(ctx, tx), &BaseDAO{conn: tx})
+	})
+}
+
+// retryableTxError 死锁、锁等待超时可以重试整个事务
+func retryableTxError(dialect string, err error) bool {
+	switch dialect {
+	case dialectPostgres:
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) {
+			_, ok := postgresRetryStates[pgErr.SQLState()]
+			return ok
+		}
+	case dialectSqlite:
+		var sqliteErr interface{ Code() int }
+		if errors.As(err, &sqliteErr) {
+			// 扩展错误码的低8位为主错误码
+			code := sqliteErr.Code() & 0xff
+			return code == sqliteBusy || code == sqliteLocked
+		}
+	default:
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) {
+			return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+		}
+	}
+	return false
+}
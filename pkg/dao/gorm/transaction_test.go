@@ -0,0 +1,183 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/go-sql-driver/mysql"
+)
+
+// sqliteCodeError 模拟 sqlite 驱动返回的错误码
+type sqliteCodeError int
+
+func (e sqliteCodeError) Error() string {
+	return "sqlite error"
+}
+
+func (e sqliteCodeError) Code() int {
+	return int(e)
+}
+
+func TestTransactionCommit(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	err := d.Transaction(context.Background(), func(ctx context.Context, tx DAO) error {
+		if err := tx.Create(ctx, &domain.Role{Name: "a"}); err != nil {
+			return err
+		}
+		// context 中带有事务，原来的 DAO 也在事务中执行
+		return d.Create(ctx, &domain.Role{Name: "b"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := roleNames(t, d); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("roles = %v, want [a b]", names)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	failed := errors.New("failed")
+	err := d.Transaction(context.Background(), func(ctx context.Context, tx DAO) error {
+		if err := tx.Create(ctx, &domain.Role{Name: "a"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("transaction error = %v, want %v", err, failed)
+	}
+	if names := roleNames(t, d); len(names) != 0 {
+		t.Fatalf("roles after rollback = %v", names)
+	}
+}
+
+// TestNestedTransaction 内层事务使用 savepoint，回滚时不影响外层事务
+func TestNestedTransaction(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	failed := errors.New("failed")
+	err := d.Transaction(context.Background(), func(ctx context.Context, tx DAO) error {
+		if err := tx.Create(ctx, &domain.Role{Name: "a"}); err != nil {
+			return err
+		}
+		inner := d.Transaction(ctx, func(ctx context.Context, tx DAO) error {
+			if err := tx.Create(ctx, &domain.Role{Name: "b"}); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(inner, failed) {
+			t.Errorf("inner transaction error = %v, want %v", inner, failed)
+		}
+		committed := d.Transaction(ctx, func(ctx context.Context, tx DAO) error {
+			return tx.Create(ctx, &domain.Role{Name: "c"})
+		})
+		if committed != nil {
+			return committed
+		}
+		return tx.Create(ctx, &domain.Role{Name: "d"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := roleNames(t, d); !reflect.DeepEqual(names, []string{"a", "c", "d"}) {
+		t.Fatalf("roles = %v, want [a c d]", names)
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	err := d.Transaction(context.Background(), func(ctx context.Context, tx DAO) error {
+		if err := tx.Create(ctx, &domain.Role{Name: "a"}); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("transaction error = %v, want PanicError", err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("panic error = %v, stack %d bytes", panicErr.Value, len(panicErr.Stack))
+	}
+	if names := roleNames(t, d); len(names) != 0 {
+		t.Fatalf("roles after panic = %v", names)
+	}
+}
+
+// TestTransactionRetry 可重试的错误重试整个事务，嵌套事务不单独重试
+func TestTransactionRetry(t *testing.T) {
+	busy := sqliteCodeError(sqliteBusy)
+	tests := []struct {
+		name     string
+		err      func(attempt int) error
+		nested   bool
+		attempts int
+		want     error
+	}{
+		{"always busy", func(int) error { return busy }, false, DefaultTxRetries + 1, busy},
+		{"busy once", func(attempt int) error {
+			if attempt == 1 {
+				return busy
+			}
+			return nil
+		}, false, 2, nil},
+		// 扩展错误码 SQLITE_BUSY_SNAPSHOT
+		{"extended code", func(int) error { return sqliteCodeError(sqliteBusy | 2<<8) }, false, DefaultTxRetries + 1, sqliteCodeError(sqliteBusy | 2<<8)},
+		{"locked in nested", func(int) error { return sqliteCodeError(sqliteLocked) }, true, DefaultTxRetries + 1, sqliteCodeError(sqliteLocked)},
+		{"not retryable", func(int) error { return sqliteCodeError(19) }, false, 1, sqliteCodeError(19)},
+		{"other dialect error", func(int) error { return &mysql.MySQLError{Number: mysqlDeadlock} }, false, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := newSqliteDAO(t)
+			attempts, nested := 0, 0
+			err := d.Transaction(context.Background(), func(ctx context.Context, tx DAO) error {
+				attempts++
+				if err := tx.Create(ctx, &domain.Role{Name: "a"}); err != nil {
+					return err
+				}
+				if !tt.nested {
+					return tt.err(attempts)
+				}
+				return d.Transaction(ctx, func(ctx context.Context, tx DAO) error {
+					nested++
+					return tt.err(attempts)
+				})
+			})
+			if attempts != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if tt.nested && nested != attempts {
+				t.Fatalf("nested attempts = %d, want %d", nested, attempts)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("transaction error = %v, want %v", err, tt.want)
+			}
+			want := []string{"a"}
+			if err != nil {
+				want = []string{}
+			}
+			if names := roleNames(t, d); !reflect.DeepEqual(names, want) {
+				t.Fatalf("roles = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+func TestTransactionRetryCanceled(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := d.Transaction(ctx, func(ctx context.Context, tx DAO) error {
+		attempts++
+		cancel()
+		return sqliteCodeError(sqliteBusy)
+	})
+	if attempts != 1 || !errors.Is(err, sqliteCodeError(sqliteBusy)) {
+		t.Fatalf("canceled transaction attempts = %d, error = %v", attempts, err)
+	}
+}
@@ -0,0 +1,212 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"time"
+)
+
+// DeletedOnlyOption 只查询已软删除的数据
+var DeletedOnlyOption = func() OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}, Value: nil})
+	}
+}
+
+func (b *BaseDAO) ListDeleted(ctx context.Context, q *api.QueryRequest, results any, opts ...OptionFunc) error {
+	if err := b.requireSoftDelete(q.Query); err != nil {
+		return err
+	}
+	return b.List(ctx, q, results, append(opts, DeletedOnlyOption())...)
+}
+
+func (b *BaseDAO) Restore(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) (int64, error) {
+	if object.IsEmpty(obj) {
+		return 0, fmt.Errorf("base dao restore error: %w", EmptyConditionError)
+	}
+	if err := b.requireSoftDelete(obj); err != nil {
+		return 0, err
+	}
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	associations, err := cascadeAssociations(result, obj)
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao restore error", "object", obj, "error", err)
+		return 0, err
+	}
+	if len(associations) == 0 {
+		values, err := restoreValues(result, obj)
+		if err != nil {
+			daoLogger(ctx).Errorw("base dao restore error", "object", obj, "error", err)
+			return 0, err
+		}
+		result = DeletedOnlyOption()(result.Model(obj).Where(obj)).Updates(values)
+		if result.Error != nil {
+			daoLogger(ctx).Errorw("base dao restore error", "object", obj, "error", result.Error)
+			return 0, fmt.Errorf("base dao restore %v error: %w", obj, result.Error)
+		}
+		return result.RowsAffected, nil
+	}
+	// 与级联删除相同，先找出满足条件的数据，再逐条恢复数据和一起删除的关联
+	var affected int64
+	err = result.Transaction(func(tx *gorm.DB) error {
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+		if err := DeletedOnlyOption()(tx.Where(obj)).Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		for i := 0; i < rows.Elem().Len(); i++ {
+			restored, err := restoreCascade(tx, rows.Elem().Index(i).Interface(), associations)
+			if err != nil {
+				return err
+			}
+			affected += restored
+		}
+		return nil
+	})
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao restore error", "object", obj, "error", err)
+		return 0, fmt.Errorf("base dao restore %v error: %w", obj, err)
+	}
+	return affected, nil
+}
+
+/*
+restoreCascade 恢复一条数据和与它同时删除的 has one、has many 关联
+many2many 删除的是关联表中的记录，无法恢复
+*/
+func restoreCascade(tx *gorm.DB, row any, associations []string) (int64, error) {
+	err := eachCascaded(tx, row, associations, func(query *gorm.DB, child any) error {
+		values, err := restoreValues(tx, child)
+		if err != nil {
+			return err
+		}
+		return query.Updates(values).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	values, err := restoreValues(tx, row)
+	if err != nil {
+		return 0, err
+	}
+	result := tx.Unscoped().Model(row).Updates(values)
+	return result.RowsAffected, result.Error
+}
+
+// purgeCascade 永久删除一条数据和与它同时删除的 has one、has many 关联，单独删除的关联不受影响
+func purgeCascade(tx *gorm.DB, row any, associations []string) (int64, error) {
+	err := eachCascaded(tx, row, associations, func(query *gorm.DB, child any) error {
+		return query.Delete(child).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	result := tx.Unscoped().Delete(row)
+	return result.RowsAffected, result.Error
+}
+
+// eachCascaded 对 row 的每个软删除的 has one、has many 关联执行 fn，query 限定为与 row 删除时间相同的关联数据
+func eachCascaded(tx *gorm.DB, row any, associations []string, fn func(query *gorm.DB, child any) error) error {
+	s, err := parseSchema(tx, row)
+	if err != nil {
+		return err
+	}
+	value := reflect.Indirect(reflect.ValueOf(row))
+	deletedAt, _ := s.FieldsByDBName[deletedAtColumn].ValueOf(tx.Statement.Context, value)
+	for _, name := range associations {
+		rel := s.Relationships.Relations[name]
+		if rel.Type != schema.HasOne && rel.Type != schema.HasMany {
+			continue
+		}
+		if _, ok := rel.FieldSchema.FieldsByDBName[deletedAtColumn]; !ok {
+			continue
+		}
+		child := reflect.New(rel.FieldSchema.ModelType).Interface()
+		query := tx.Unscoped().Model(child).
+			Clauses(clause.Where{Exprs: rel.ToQueryConditions(tx.Statement.Context, value)}).
+			Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}, Value: deletedAt})
+		if err = fn(query, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreValues 恢复时更新的列，版本号递增，更新时间由 gorm 更新，使恢复前的 ETag 失效
+func restoreValues(db *gorm.DB, model any) (map[string]any, error) {
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{deletedAtColumn: nil}
+	if _, ok := s.FieldsByDBName[versionColumn]; ok {
+		values[versionColumn] = gorm.Expr("? + 1", clause.Column{Name: versionColumn})
+	}
+	return values, nil
+}
+
+func (b *BaseDAO) Purge(ctx context.Context, model domain.Indexer, olderThan time.Time, opts ...OptionFunc) (int64, error) {
+	if err := b.requireSoftDelete(model); err != nil {
+		return 0, err
+	}
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	expired := clause.Lt{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}, Value: olderThan}
+	associations, err := cascadeAssociations(result, model)
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao purge error", "model", object.ClassName(model), "error", err)
+		return 0, err
+	}
+	if len(associations) == 0 {
+		result = result.Unscoped().Where(expired).Delete(model)
+		if result.Error != nil {
+			daoLogger(ctx).Errorw("base dao purge error", "model", object.ClassName(model), "error", result.Error)
+			return 0, fmt.Errorf("base dao purge %s error: %w", object.ClassName(model), result.Error)
+		}
+		return result.RowsAffected, nil
+	}
+	// 与恢复相同，逐条删除数据和一起删除的关联，返回的行数不包括关联
+	var affected int64
+	err = result.Transaction(func(tx *gorm.DB) error {
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(model)))
+		if err := tx.Unscoped().Where(expired).Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		for i := 0; i < rows.Elem().Len(); i++ {
+			purged, err := purgeCascade(tx, rows.Elem().Index(i).Interface(), associations)
+			if err != nil {
+				return err
+			}
+			affected += purged
+		}
+		return nil
+	})
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao purge error", "model", object.ClassName(model), "error", err)
+		return 0, fmt.Errorf("base dao purge %s error: %w", object.ClassName(model), err)
+	}
+	return affected, nil
+}
+
+// requireSoftDelete 模型需要有 deleted_at 列
+func (b *BaseDAO) requireSoftDelete(model any) error {
+	s, err := parseSchema(b.conn, model)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.FieldsByDBName[deletedAtColumn]; !ok {
+		return fmt.Errorf("base dao error, %s is not soft deleted model", object.ClassName(model))
+	}
+	return nil
+}
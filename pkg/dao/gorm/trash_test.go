@@ -0,0 +1,203 @@
+package gorm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/domain"
+)
+
+type cascadeParent struct {
+	domain.Model
+	domain.Versioning
+	Name     string         `json:"name" gorm:"column:name"`
+	Children []cascadeChild `json:"children" gorm:"foreignKey:ParentID"`
+}
+
+func (p *cascadeParent) Preloads() []string {
+	return []string{"Children"}
+}
+
+type cascadeChild struct {
+	domain.Model
+	domain.Versioning
+	ParentID uint   `json:"parent_id" gorm:"column:parent_id"`
+	Name     string `json:"name" gorm:"column:name"`
+}
+
+func TestRestoreCascade(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.conn.AutoMigrate(&cascadeParent{}, &cascadeChild{}); err != nil {
+		t.Fatal(err)
+	}
+	parent := &cascadeParent{Name: "parent", Children: []cascadeChild{{Name: "cascaded"}, {Name: "deleted"}}}
+	if err := d.Create(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	// 先单独删除的关联不随主对象恢复
+	if _, err := d.Delete(ctx, &cascadeChild{Name: "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	if affected, err := d.Delete(ctx, &cascadeParent{Name: "parent"}); err != nil || affected != 1 {
+		t.Fatalf("delete parent = %d, %v", affected, err)
+	}
+	deleted := &cascadeParent{}
+	if err := d.conn.Unscoped().First(deleted, parent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	affected, err := d.Restore(ctx, &cascadeParent{Name: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 1 {
+		t.Fatalf("restore affected = %d, want 1", affected)
+	}
+	restored := &cascadeParent{}
+	if err = d.Get(ctx, restored, PreloadOption("Children")); err != nil {
+		t.Fatalf("get restored parent error = %v", err)
+	}
+	if restored.Version != deleted.Version+1 {
+		t.Fatalf("restored version = %d, want %d", restored.Version, deleted.Version+1)
+	}
+	if !restored.UpdatedAt.After(deleted.UpdatedAt) {
+		t.Fatalf("restored updated_at %v should be after %v", restored.UpdatedAt, deleted.UpdatedAt)
+	}
+	if len(restored.Children) != 1 || restored.Children[0].Name != "cascaded" {
+		t.Fatalf("restored children = %+v, want only cascaded", restored.Children)
+	}
+	if restored.Children[0].Version != 2 {
+		t.Fatalf("restored child version = %d, want 2", restored.Children[0].Version)
+	}
+	if err = d.Get(ctx, &cascadeChild{Name: "deleted"}); err == nil {
+		t.Fatal("child deleted before parent should stay deleted")
+	}
+}
+
+// TestRestoreVersion 没有关联时也递增版本号，恢复前的 ETag 不再匹配
+func TestRestoreVersion(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.conn.AutoMigrate(&cascadeChild{}); err != nil {
+		t.Fatal(err)
+	}
+	child := &cascadeChild{Name: "child"}
+	if err := d.Create(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Delete(ctx, &cascadeChild{Name: "child"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if affected, err := d.Restore(ctx, &cascadeChild{Name: "child"}); err != nil || affected != 1 {
+		t.Fatalf("restore = %d, %v", affected, err)
+	}
+	restored := &cascadeChild{Name: "child"}
+	if err := d.Get(ctx, restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != 2 {
+		t.Fatalf("restored version = %d, want 2", restored.Version)
+	}
+	if !restored.UpdatedAt.After(child.UpdatedAt) {
+		t.Fatalf("restored updated_at %v should be after %v", restored.UpdatedAt, child.UpdatedAt)
+	}
+	stale := &cascadeChild{Model: domain.Model{ID: child.ID}, Versioning: domain.Versioning{Version: child.Version}, Name: "stale"}
+	if err := d.Update(ctx, stale); err == nil {
+		t.Fatal("update with version before restore should conflict")
+	}
+}
+
+func unscopedNames(t *testing.T, d *BaseDAO, model any) []string {
+	t.Helper()
+	var names []string
+	if err := d.conn.Unscoped().Model(model).Order("name").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// TestPurgeCascade 一起删除的关联随主对象永久删除，单独删除的关联和未过期的数据保留
+func TestPurgeCascade(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.conn.AutoMigrate(&cascadeParent{}, &cascadeChild{}); err != nil {
+		t.Fatal(err)
+	}
+	parents := []*cascadeParent{
+		{Name: "expired", Children: []cascadeChild{{Name: "cascaded"}, {Name: "separate"}}},
+		{Name: "recent", Children: []cascadeChild{{Name: "recent child"}}},
+		{Name: "live", Children: []cascadeChild{{Name: "live child"}}},
+	}
+	for _, p := range parents {
+		if err := d.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Delete(ctx, &cascadeChild{Name: "separate"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := d.Delete(ctx, &cascadeParent{Name: "expired"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	olderThan := time.Now()
+	time.Sleep(time.Millisecond)
+	if _, err := d.Delete(ctx, &cascadeParent{Name: "recent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	affected, err := d.Purge(ctx, &cascadeParent{}, olderThan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 1 {
+		t.Fatalf("purge affected = %d, want 1", affected)
+	}
+	if names := unscopedNames(t, d, &cascadeParent{}); !reflect.DeepEqual(names, []string{"live", "recent"}) {
+		t.Fatalf("parents after purge = %v, want [live recent]", names)
+	}
+	want := []string{"live child", "recent child", "separate"}
+	if names := unscopedNames(t, d, &cascadeChild{}); !reflect.DeepEqual(names, want) {
+		t.Fatalf("children after purge = %v, want %v", names, want)
+	}
+	// 单独删除的关联仍在回收站中
+	if err = d.Get(ctx, &cascadeChild{Name: "separate"}, SoftDeleteOption()); err != nil {
+		t.Fatalf("get separately deleted child error = %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	if err := d.CreateBatch(ctx, []*domain.Template{{Name: "expired"}, {Name: "recent"}, {Name: "live"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Delete(ctx, &domain.Template{Name: "expired"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	olderThan := time.Now()
+	time.Sleep(time.Millisecond)
+	if _, err := d.Delete(ctx, &domain.Template{Name: "recent"}); err != nil {
+		t.Fatal(err)
+	}
+	if affected, err := d.Purge(ctx, &domain.Template{}, olderThan); err != nil || affected != 1 {
+		t.Fatalf("purge = %d, %v, want 1", affected, err)
+	}
+	if names := unscopedNames(t, d, &domain.Template{}); !reflect.DeepEqual(names, []string{"live", "recent"}) {
+		t.Fatalf("templates after purge = %v, want [live recent]", names)
+	}
+	if _, err := d.Purge(ctx, &hardDeleteItem{}, olderThan); err == nil {
+		t.Fatal("purge model without deleted_at should fail")
+	}
+}
+
+type hardDeleteItem struct {
+	domain.HardDeleteModel
+	Name string `json:"name" gorm:"column:name"`
+}
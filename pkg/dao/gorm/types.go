@@ -4,10 +4,12 @@ import (
 	"context"
 	"github.com/MoWan-inc/aqua/pkg/api"
 	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 	"sync"
+	"time"
 )
 
 type Transaction interface {
@@ -21,20 +23,47 @@ type DAO interface {
 	Begin() Transaction
 	// WithTransaction 使用制定事务操作
 	WithTransaction(tx Transaction) DAO
-	// Count 默认支持的操作
+	// Transaction 在事务中执行 fn，fn 返回错误或 panic 时回滚，否则提交
+	// 事务通过 ctx 传递，使用该 ctx 的 DAO 操作和嵌套的 Transaction（savepoint）复用同一事务
+	// 死锁、锁等待超时等可重试的错误时按退避重试整个事务，fn 需要可以重复执行
+	Transaction(ctx context.Context, fn func(ctx context.Context, tx DAO) error) error
+	// Count 默认支持的操作，Count、Aggregate、List、Get、ListWithInClause 在配置副本时读取副本，见 PrimaryOption
 	Count(ctx context.Context, q *api.QueryRequest, opts ...OptionFunc) (int64, error)
+	// Aggregate 按分组列聚合过滤后的数据，每行包含分组列和聚合函数的结果
+	// 未分页时分组数超过上限返回 TooManyGroupsError，不会截断结果
+	Aggregate(ctx context.Context, q *api.QueryRequest, agg *api.Aggregation, opts ...OptionFunc) ([]map[string]any, error)
 	// List 查询操作
 	List(ctx context.Context, q *api.QueryRequest, results any, opts ...OptionFunc) error
 	Get(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
 	// ListWithInClause 含有IN语句的查询操作，手动输入需要的column和in clause value进行查询
-	ListWithInClause(ctx context.Context, results any, query string, inClause [][]any) error
-	// Delete 级联删除说明：传入的对象需要将外键引用的属性也赋值，否则不会触发GORM级联删除
-	Delete(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
+	ListWithInClause(ctx context.Context, results any, query string, inClause [][]any, opts ...OptionFunc) error
+	// Delete 按对象中的非空字段删除，返回删除的行数，不允许不带条件
+	// domain.Model 为软删除，domain.HardDeleteModel 或使用 SoftDeleteOption 时为硬删除
+	// 级联删除模型通过 domain.Preload、domain.Relation 声明的关联
+	Delete(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) (int64, error)
+	// ListDeleted 查询已软删除的数据，参数与 List 相同
+	ListDeleted(ctx context.Context, q *api.QueryRequest, results any, opts ...OptionFunc) error
+	// Restore 恢复按对象中非空字段匹配的软删除数据，返回恢复的行数
+	// 级联删除的关联在同一事务中一起恢复，版本号和更新时间随之更新
+	Restore(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) (int64, error)
+	// Purge 永久删除 olderThan 之前软删除的数据，model 只用于确定表
+	// 与 Restore 相同，同时永久删除与数据一起级联删除的关联，返回的行数不包括关联
+	Purge(ctx context.Context, model domain.Indexer, olderThan time.Time, opts ...OptionFunc) (int64, error)
 	Create(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
 	// Update 只更新非空字段
 	Update(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
 	// Save 覆盖式更新
 	Save(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
+	// Upsert 按唯一索引创建或更新，见 UpsertColumnsOption、ReviveDeletedOption
+	Upsert(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error
+	// CreateBatch 批量创建，objs 为模型指针的切片，在事务中按 BatchSizeOption 分批写入，失败时返回 BatchError
+	CreateBatch(ctx context.Context, objs any, opts ...OptionFunc) error
+	// UpsertBatch 批量创建，唯一索引（没有时为主键）冲突时更新，选项与 Upsert 相同
+	UpsertBatch(ctx context.Context, objs any, opts ...OptionFunc) error
+	// DeleteWhere 删除满足查询条件的数据，不允许不带条件
+	// 只执行一条删除语句，不级联删除关联，需要级联时使用 Delete
+	// 返回数据库报告的行数，软删除时不包括已经被软删除的行
+	DeleteWhere(ctx context.Context, q *api.QueryRequest, opts ...OptionFunc) (int64, error)
 }
 
 type OptionFunc func(*gorm.DB) *gorm.DB
@@ -66,7 +95,7 @@ var PreloadOption = func(associations ...string) OptionFunc {
 	}
 }
 
-// SoftDeleteOption 操作软删除的记录
+// SoftDeleteOption 操作软删除的记录，删除时为硬删除
 var SoftDeleteOption = func() OptionFunc {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Unscoped()
@@ -80,13 +109,23 @@ var OmitAssociationOption = func() OptionFunc {
 	}
 }
 
-// LockRowUpdateOption 锁住update操作
+// LockRowUpdateOption 锁住update操作，SQLite 不支持行锁，写事务本身是串行的，忽略该选项
 var LockRowUpdateOption = func() OptionFunc {
 	return func(db *gorm.DB) *gorm.DB {
+		if db.Dialector.Name() == dialectSqlite {
+			return db
+		}
 		return db.Clauses(clause.Locking{Strength: "UPDATE"})
 	}
 }
 
+// SelectOption 只查询指定的列，列名必须是 model 的gorm列，主键总会被查询
+var SelectOption = func(model any, columns ...string) OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return prepareSelect(columns, domain.GetGormFields(object.ClassName(model)), db)
+	}
+}
+
 // GetOptions : 获取domain对象的查询条件，用于关联查询
 func GetOptions(obj any) []OptionFunc {
 	var opts []OptionFunc
@@ -0,0 +1,229 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+const (
+	upsertColumnsKey = "aqua:upsert_columns"
+	upsertReviveKey  = "aqua:upsert_revive"
+
+	deletedAtColumn = "deleted_at"
+)
+
+// DeletedConflictError 唯一索引与已软删除的数据冲突，Upsert 未使用 ReviveDeletedOption、Save 和 Update 未使用 SoftDeleteOption 时不会更新
+var DeletedConflictError = errors.New("conflict with soft deleted row")
+
+// UpsertColumnsOption 唯一索引冲突时只更新指定的列，默认更新除主键和创建时间外的全部列
+var UpsertColumnsOption = func(columns ...string) OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(upsertColumnsKey, columns)
+	}
+}
+
+// ReviveDeletedOption 唯一索引与已软删除的数据冲突时，恢复并更新该数据
+var ReviveDeletedOption = func() OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(upsertReviveKey, true)
+	}
+}
+
+/*
+Upsert 单条语句完成创建或更新，冲突列为模型的唯一索引列，没有唯一索引时为主键
+默认不会更新已软删除的数据，此时返回 DeletedConflictError，冲突的数据是否已删除按冲突列重新读取判断
+*/
+func (b *BaseDAO) Upsert(ctx context.Context, obj domain.Indexer, opts ...OptionFunc) error {
+	result := b.db(ctx)
+	for _, o := range opts {
+		result = o(result)
+	}
+	conflict, err := upsertClause(result, obj)
+	if err != nil {
+		daoLogger(ctx).Errorw("base dao upsert error", "object", obj, "error", err)
+		return err
+	}
+	if result.Dialector.Name() == dialectMysql {
+		// 更新时 LAST_INSERT_ID 返回被更新行的主键，gorm 据此回填主键
+		if s, err := parseSchema(result, obj); err == nil && s.PrioritizedPrimaryField != nil {
+			pk := clause.Column{Name: s.PrioritizedPrimaryField.DBName}
+			conflict.DoUpdates = append(conflict.DoUpdates, clause.Assignment{
+				Column: pk, Value: clause.Expr{SQL: "LAST_INSERT_ID(?)", Vars: []any{pk}},
+			})
+		}
+	}
+	result = result.Clauses(conflict).Create(obj)
+	if result.Error != nil {
+		daoLogger(ctx).Errorw("base dao upsert error", "object", obj, "error", result.Error)
+		return result.Error
+	}
+	// MySQL 更新没有改变任何列时也不影响行，需要重新读取冲突的数据判断是否已被软删除
+	if result.RowsAffected == 0 && !upsertRevive(result) {
+		deleted, err := b.upsertDeleted(ctx, obj, conflict.Columns)
+		if err != nil {
+			daoLogger(ctx).Errorw("base dao upsert error", "object", obj, "error", err)
+			return fmt.Errorf("base dao upsert %v error: %w", obj, err)
+		}
+		if deleted {
+			return fmt.Errorf("base dao upsert %v error: %w", obj, DeletedConflictError)
+		}
+	}
+	return nil
+}
+
+// upsertDeleted 按冲突列读取冲突的数据，返回是否已被软删除，未删除时回填主键
+func (b *BaseDAO) upsertDeleted(ctx context.Context, obj domain.Indexer, columns []clause.Column) (bool, error) {
+	s, err := parseSchema(b.conn, obj)
+	if err != nil {
+		return false, err
+	}
+	deletedAt, ok := s.FieldsByDBName[deletedAtColumn]
+	if !ok || len(columns) == 0 {
+		return false, nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(obj))
+	exprs := make([]clause.Expression, 0, len(columns))
+	for _, c := range columns {
+		field, ok := s.FieldsByDBName[c.Name]
+		if !ok {
+			return false, fmt.Errorf("invalid conflict column %s", c.Name)
+		}
+		v, _ := field.ValueOf(ctx, value)
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: c.Name}, Value: v})
+	}
+	// 与写入在同一连接或事务中读取，不读副本
+	row := reflect.New(s.ModelType)
+	result := b.db(ctx).Unscoped().Where(clause.And(exprs...)).Limit(1).Find(row.Interface())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if _, zero := deletedAt.ValueOf(ctx, row.Elem()); !zero {
+		return true, nil
+	}
+	if indexer, ok := row.Interface().(domain.Indexer); ok && reflect.ValueOf(obj.Key()).IsZero() {
+		if err = obj.SetKey(indexer.Key()); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// upsertClause 冲突列为唯一索引的列，更新的列可以由 UpsertColumnsOption 指定，rows 为模型指针或其切片
+func upsertClause(db *gorm.DB, rows any) (clause.OnConflict, error) {
+	conflict := clause.OnConflict{}
+	model := rows
+	if value := reflect.Indirect(reflect.ValueOf(rows)); value.Kind() == reflect.Slice {
+		if value.Len() == 0 {
+			return conflict, nil
+		}
+		model = value.Index(0).Interface()
+	}
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return conflict, err
+	}
+	conflict.Columns, err = conflictColumns(db, s, model)
+	if err != nil {
+		return conflict, err
+	}
+	columns, err := upsertColumns(db, s, model)
+	if err != nil {
+		return conflict, err
+	}
+	_, softDelete := s.FieldsByDBName[deletedAtColumn]
+	revive := upsertRevive(db)
+	// 不恢复软删除的数据时，只更新未删除的行，MySQL 不支持 WHERE，在赋值中判断
+	ifNotDeleted := softDelete && !revive && db.Dialector.Name() == dialectMysql
+	if softDelete && !revive {
+		conflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}, Value: nil},
+		}}
+	}
+	for _, c := range columns {
+		column := clause.Column{Name: c}
+		var v any = upsertValue(column)
+		if ifNotDeleted {
+			v = clause.Expr{SQL: "IF(? IS NULL, VALUES(?), ?)", Vars: []any{
+				clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn}, column,
+				clause.Column{Table: clause.CurrentTable, Name: c},
+			}}
+		}
+		conflict.DoUpdates = append(conflict.DoUpdates, clause.Assignment{Column: column, Value: v})
+	}
+	if softDelete && revive {
+		deletedAt := clause.Column{Name: deletedAtColumn}
+		conflict.DoUpdates = append(conflict.DoUpdates, clause.Assignment{Column: deletedAt, Value: upsertValue(deletedAt)})
+	}
+	return conflict, nil
+}
+
+// conflictColumns 唯一索引中有值的列，没有唯一索引时为主键
+func conflictColumns(db *gorm.DB, s *schema.Schema, model any) ([]clause.Column, error) {
+	var columns []clause.Column
+	if uniq, ok := model.(domain.UniqueIndex); ok {
+		if indexer := uniq.UniqIndexer(); indexer != nil {
+			is, err := parseSchema(db, indexer)
+			if err != nil {
+				return nil, err
+			}
+			indexValue := reflect.Indirect(reflect.ValueOf(indexer))
+			for _, field := range is.Fields {
+				if len(field.DBName) == 0 {
+					continue
+				}
+				if _, zero := field.ValueOf(db.Statement.Context, indexValue); !zero {
+					columns = append(columns, clause.Column{Name: field.DBName})
+				}
+			}
+		}
+	}
+	if len(columns) == 0 {
+		for _, field := range s.PrimaryFields {
+			columns = append(columns, clause.Column{Name: field.DBName})
+		}
+	}
+	return columns, nil
+}
+
+// upsertColumns 冲突时更新的列，默认为除主键、创建时间和删除时间外的全部列
+func upsertColumns(db *gorm.DB, s *schema.Schema, model any) ([]string, error) {
+	if v, ok := db.Get(upsertColumnsKey); ok {
+		columns, _ := v.([]string)
+		fields := domain.GetGormFields(object.ClassName(model))
+		for _, c := range columns {
+			if _, err := gormColumn(fields, c); err != nil {
+				return nil, err
+			}
+		}
+		return columns, nil
+	}
+	columns := make([]string, 0, len(s.DBNames))
+	for _, name := range s.DBNames {
+		field := s.FieldsByDBName[name]
+		if field.PrimaryKey || field.AutoCreateTime > 0 || name == deletedAtColumn {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+func upsertRevive(db *gorm.DB) bool {
+	v, ok := db.Get(upsertReviveKey)
+	return ok && v == true
+}
+
+// upsertValue 插入语句中该列的值，MySQL 中由驱动转换为 VALUES(col)
+func upsertValue(column clause.Column) any {
+	return clause.Column{Table: "excluded", Name: column.Name}
+}
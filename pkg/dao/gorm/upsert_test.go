@@ -0,0 +1,78 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"gorm.io/gorm"
+)
+
+// mysqlNoopUpsert 模拟 MySQL 更新没有改变任何列时影响行数为0
+func mysqlNoopUpsert(t *testing.T, d *BaseDAO) {
+	t.Helper()
+	err := d.conn.Callback().Create().After("gorm:create").Register("test:noop_upsert", func(db *gorm.DB) {
+		db.RowsAffected = 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpsertNoop(t *testing.T) {
+	d, _ := newSqliteDAO(t)
+	ctx := context.Background()
+	role := &domain.Role{Name: "ops"}
+	if err := d.Create(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	mysqlNoopUpsert(t, d)
+	upserted := &domain.Role{Name: "ops"}
+	if err := d.Upsert(ctx, upserted, UpsertColumnsOption("permissions")); err != nil {
+		t.Fatalf("noop upsert error = %v", err)
+	}
+	if upserted.ID != role.ID {
+		t.Fatalf("noop upsert id = %d, want %d", upserted.ID, role.ID)
+	}
+}
+
+func TestUpsertDeletedConflict(t *testing.T) {
+	for name, noop := range map[string]bool{"updated": false, "noop": true} {
+		t.Run(name, func(t *testing.T) {
+			d, _ := newSqliteDAO(t)
+			ctx := context.Background()
+			role := &domain.Role{Name: "ops"}
+			if err := d.Create(ctx, role); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Delete(ctx, &domain.Role{Name: "ops"}); err != nil {
+				t.Fatal(err)
+			}
+			if noop {
+				mysqlNoopUpsert(t, d)
+			}
+			if err := d.Upsert(ctx, &domain.Role{Name: "ops"}); !errors.Is(err, DeletedConflictError) {
+				t.Fatalf("upsert deleted error = %v, want %v", err, DeletedConflictError)
+			}
+			if err := d.Get(ctx, &domain.Role{Name: "ops"}); !errors.Is(err, NotExistsError) {
+				t.Fatalf("get after upsert deleted error = %v, want %v", err, NotExistsError)
+			}
+			// Save 按唯一索引查找时不包含已删除的数据，不会恢复
+			if err := d.Save(ctx, &domain.Role{Name: "ops"}); !errors.Is(err, DeletedConflictError) {
+				t.Fatalf("save deleted error = %v, want %v", err, DeletedConflictError)
+			}
+			revived := &domain.Role{Name: "ops"}
+			if err := d.Upsert(ctx, revived, ReviveDeletedOption()); err != nil {
+				t.Fatal(err)
+			}
+			found := &domain.Role{Name: "ops"}
+			if err := d.Get(ctx, found); err != nil {
+				t.Fatalf("get revived error = %v", err)
+			}
+			if found.ID != role.ID {
+				t.Fatalf("revived id = %d, want %d", found.ID, role.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	versionColumn   = "version"
+	updatedAtColumn = "updated_at"
+
+	ifUnmodifiedKey = "aqua:if_unmodified"
+)
+
+// ConflictError 乐观锁冲突，数据已被其他请求修改或已删除
+type ConflictError struct {
+	Key any
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict error, %v has been modified or deleted", e.Key)
+}
+
+// IfUnmodifiedOption 只在数据的更新时间仍为 updatedAt 时更新，用于没有实现 domain.Versioned 的模型
+// 数据库时间精度为毫秒，按毫秒比较
+var IfUnmodifiedOption = func(updatedAt time.Time) OptionFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(ifUnmodifiedKey, updatedAt)
+	}
+}
+
+/*
+prepareVersion 添加乐观锁条件，返回是否需要检查影响行数
+domain.Versioned 对象未指定版本时以当前版本为准，版本号仍然递增，用于内部调用；接口层要求请求指定版本
+*/
+func (b *BaseDAO) prepareVersion(ctx context.Context, result *gorm.DB, obj domain.Indexer) (*gorm.DB, bool, error) {
+	checked := false
+	if v, ok := obj.(domain.Versioned); ok {
+		expected := v.GetVersion()
+		if expected == 0 {
+			current, err := b.currentVersion(ctx, obj)
+			if err != nil {
+				return result, false, err
+			}
+			expected = current
+		}
+		result = result.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: versionColumn}, Value: expected})
+		v.SetVersion(expected + 1)
+		checked = true
+	}
+	if t, ok := result.Get(ifUnmodifiedKey); ok {
+		updatedAt, _ := t.(time.Time)
+		// 数据库中的时间可能由纳秒四舍五入到毫秒
+		from := updatedAt.Truncate(time.Millisecond)
+		column := clause.Column{Table: clause.CurrentTable, Name: updatedAtColumn}
+		result = result.Where(clause.Gte{Column: column, Value: from}).
+			Where(clause.Lt{Column: column, Value: from.Add(2 * time.Millisecond)})
+		checked = true
+	}
+	return result, checked, nil
+}
+
+func (b *BaseDAO) currentVersion(ctx context.Context, obj domain.Indexer) (uint, error) {
+	var versions []uint
+	s, err := parseSchema(b.conn, obj)
+	if err != nil {
+		return 0, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return 0, fmt.Errorf("base dao version error, %s has no primary key", s.Name)
+	}
+	pk := clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName}
+	// If-Match、乐观锁的版本以主库为准，不读可能延迟的副本
+	err = PrimaryOption()(b.db(ctx)).Model(obj).
+		Where(clause.Eq{Column: pk, Value: obj.Key()}).
+		Pluck(versionColumn, &versions).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, NotExistsError
+	}
+	return versions[0], nil
+}
+
+// rollbackVersion 更新失败时恢复对象的版本号
+func rollbackVersion(obj domain.Indexer) {
+	if v, ok := obj.(domain.Versioned); ok && v.GetVersion() > 0 {
+		v.SetVersion(v.GetVersion() - 1)
+	}
+}
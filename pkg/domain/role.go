@@ -0,0 +1,25 @@
+package domain
+
+// Role 角色及其权限，权限以json存储
+type Role struct {
+	Model
+	Name        string       `json:"name" gorm:"column:name;uniqueIndex;size:64"`
+	Permissions []Permission `json:"permissions" gorm:"column:permissions;serializer:json"`
+}
+
+func (r *Role) UniqIndexer() Indexer {
+	if len(r.Name) == 0 {
+		return nil
+	}
+	return &Role{Name: r.Name}
+}
+
+func (r *Role) DefaultSort() string {
+	return "name"
+}
+
+// Permission resource 为 DomainPath 中的路由，verbs 为 HTTP 方法，* 表示全部
+type Permission struct {
+	Resource string   `json:"resource"`
+	Verbs    []string `json:"verbs"`
+}
@@ -2,5 +2,5 @@ package domain
 
 type Template struct {
 	Model
-	Name string `json:"name"`
+	Name string `json:"name" gorm:"column:name"`
 }
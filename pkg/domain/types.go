@@ -26,6 +26,7 @@ type UniqueIndex interface {
 // 路由映射
 var DomainPath = map[string]string{
 	object.ClassName(Template{}): "template",
+	object.ClassName(Role{}):     "role",
 }
 
 // Relation 关联关系，返回关联表名或对象，限于一对一关系
@@ -40,6 +41,28 @@ type Preload interface {
 	Preloads() []string
 }
 
+// Versioned 乐观锁版本号，对应 version 列，更新时检查版本并递增，创建时为1
+// 没有实现该接口的模型可以使用 UpdatedTime 做乐观锁
+type Versioned interface {
+	GetVersion() uint
+	SetVersion(version uint)
+}
+
+// Versioning 嵌入模型中实现 Versioned
+type Versioning struct {
+	Version uint `json:"version" gorm:"column:version;not null;default:1"`
+}
+
+func (v *Versioning) GetVersion() uint { return v.Version }
+
+func (v *Versioning) SetVersion(version uint) { v.Version = version }
+
+// DefaultSorting 未指定排序时的默认排序，语法同 api.ParseSort，例如 -priority,name
+// 列表总会以主键作为最后的排序列，保证分页稳定
+type DefaultSorting interface {
+	DefaultSort() string
+}
+
 // Model 软删除模型
 type Model struct {
 	ID        uint           `form:"id" json:"id,omitempty" gorm:"column:id; primarykey"`
@@ -183,13 +206,19 @@ const (
 
 // model 运行时不变，缓存起来即可
 var gormModelFields = map[string]map[string]any{}
+
+// gorm列对应的json字段名
+var gormModelJSONFields = map[string]map[string]string{}
 var gormModelTypes = map[string]reflect.Type{}
 
 func init() {
-	modelTypes := []reflect.Type{
-		reflect.ValueOf(Template{}).Type(),
-	}
-	for _, modelType := range modelTypes {
+	RegisterModel(Template{}, Role{})
+}
+
+// RegisterModel 注册模型的gorm列，只有注册过的模型可以按列过滤、排序，需要在使用前调用
+func RegisterModel(models ...any) {
+	for _, model := range models {
+		modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
 		initGormFields(modelType)
 		gormModelTypes[modelType.Name()] = modelType
 	}
@@ -205,6 +234,10 @@ func getBaseFields() map[string]any {
 
 func initGormFields(modelType reflect.Type) map[string]any {
 	fields := getBaseFields()
+	jsonFields := map[string]string{}
+	for key := range fields {
+		jsonFields[key] = key
+	}
 	for i := 0; i < modelType.NumField(); i++ {
 		f := modelType.Field(i)
 		if !ast.IsExported(f.Name) {
@@ -239,12 +272,17 @@ func initGormFields(modelType reflect.Type) map[string]any {
 			for key := range embedFields {
 				fields[key] = struct{}{}
 			}
+			for key, name := range gormModelJSONFields[f.Type.Name()] {
+				jsonFields[key] = name
+			}
 		} else if len(fieldColumn) > 0 {
 			// 无嵌套属性，则采用本列属性说明
 			fields[fieldColumn] = struct{}{}
+			jsonFields[fieldColumn] = jsonFieldName(f)
 		} // 如果无列属性说明，则不作为gorm列
 	}
 	gormModelFields[modelType.Name()] = fields
+	gormModelJSONFields[modelType.Name()] = jsonFields
 	return fields
 }
 
@@ -256,6 +294,39 @@ func GetGormFields(cls string) map[string]any {
 	return map[string]any{}
 }
 
+// GetJSONFields gorm列对应的json字段名
+func GetJSONFields(cls string) map[string]string {
+	jsonFields, ok := gormModelJSONFields[cls]
+	if ok {
+		return jsonFields
+	}
+	return map[string]string{}
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if len(name) == 0 {
+		return f.Name
+	}
+	return name
+}
+
+// NewModelOfPath 根据 DomainPath 中的路由创建模型对象
+func NewModelOfPath(path string) (Indexer, bool) {
+	for cls, p := range DomainPath {
+		if p != path {
+			continue
+		}
+		t, ok := gormModelTypes[cls]
+		if !ok {
+			return nil, false
+		}
+		model, ok := reflect.New(t).Interface().(Indexer)
+		return model, ok
+	}
+	return nil, false
+}
+
 func IsTypeValid(t string) bool {
 	_, ok := gormModelTypes[t]
 	return ok
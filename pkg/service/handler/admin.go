@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// adminHandler 运维管理接口，必须通过认证并有 admin 权限
+type adminHandler struct {
+	builder *log.Builder
+}
+
+type setLogLevelRequest struct {
+	// 命名日志，例如 dao、http，为空时修改全局级别
+	Name string `json:"name"`
+	// 修改命名日志时为空表示恢复跟随全局级别
+	Level string `json:"level"`
+}
+
+// registerAdminHandler 需要在全局认证中间件之后注册，权限见 serviceutil.RequireAdmin
+func registerAdminHandler(engine *gin.Engine, authorizer *serviceutil.Authorizer) {
+	h := &adminHandler{builder: log.GetBuilder()}
+	group := engine.Group(serviceutil.AdminResource, serviceutil.RequireUser(), serviceutil.RequireAdmin(authorizer))
+	group.GET("/log/level", serviceutil.HandleAPIWithLimiter(h.getLogLevel))
+	group.PUT("/log/level", serviceutil.HandleAPIWithLimiter(h.setLogLevel))
+}
+
+func (h *adminHandler) getLogLevel(_ *gin.Context) (any, error) {
+	return h.builder.Level(), nil
+}
+
+func (h *adminHandler) setLogLevel(c *gin.Context) (any, error) {
+	req := &setLogLevelRequest{}
+	if err := c.ShouldBindWith(req, binding.JSON); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	var err error
+	if len(req.Name) > 0 {
+		err = h.builder.SetNamedLevel(req.Name, req.Level)
+	} else if len(req.Level) > 0 {
+		err = h.builder.SetLevel(req.Level)
+	} else {
+		err = errors.New("log level is required")
+	}
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	log.Infof("log level changed by %v: %+v", c.Value(serviceutil.UsrKey), req)
+	return h.builder.Level(), nil
+}
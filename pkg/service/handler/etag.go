@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// etag 实现 domain.Versioned 的模型为版本号，其余为毫秒精度的更新时间
+func etag(obj domain.Indexer) string {
+	if v, ok := obj.(domain.Versioned); ok {
+		return fmt.Sprintf(`"v%d"`, v.GetVersion())
+	}
+	if t := obj.UpdatedTime(); !t.IsZero() {
+		return fmt.Sprintf(`"t%d"`, t.UnixMilli())
+	}
+	return ""
+}
+
+func setETag(c *gin.Context, obj domain.Indexer) {
+	if tag := etag(obj); len(tag) > 0 {
+		c.Header(headerETag, tag)
+	}
+}
+
+/*
+ifMatchOptions 根据 If-Match 设置乐观锁条件，返回是否带有 If-Match
+版本号以 If-Match 为准，覆盖请求体中的版本；"*" 表示不检查
+*/
+func ifMatchOptions(c *gin.Context, obj domain.Indexer) ([]aquadao.OptionFunc, bool, error) {
+	header := strings.TrimSpace(c.GetHeader(headerIfMatch))
+	if len(header) == 0 || header == "*" {
+		return nil, false, nil
+	}
+	tag := strings.Trim(header, `"`)
+	if len(tag) < 2 {
+		return nil, true, fmt.Errorf("invalid If-Match %s", header)
+	}
+	n, err := strconv.ParseUint(tag[1:], 10, 64)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid If-Match %s", header)
+	}
+	v, versioned := obj.(domain.Versioned)
+	switch {
+	case tag[0] == 'v' && versioned:
+		v.SetVersion(uint(n))
+		return nil, true, nil
+	case tag[0] == 't' && !versioned:
+		return []aquadao.OptionFunc{aquadao.IfUnmodifiedOption(time.UnixMilli(int64(n)))}, true, nil
+	}
+	return nil, true, fmt.Errorf("invalid If-Match %s", header)
+}
+
+/*
+requireVersion 实现 domain.Versioned 的模型更新时必须通过 If-Match 或请求体指定版本，否则返回428
+避免没有读取过数据的请求覆盖其他请求的修改；If-Match 为 "*" 时表示明确不检查
+*/
+func requireVersion(c *gin.Context, obj domain.Indexer) error {
+	v, ok := obj.(domain.Versioned)
+	if !ok || v.GetVersion() > 0 || len(strings.TrimSpace(c.GetHeader(headerIfMatch))) > 0 {
+		return nil
+	}
+	return serviceutil.NewStatusError(http.StatusPreconditionRequired,
+		fmt.Errorf("version or %s is required", headerIfMatch))
+}
+
+// conflictError 带 If-Match 的请求冲突时返回412，否则返回409，唯一索引与已软删除的数据冲突时返回409，数据不存在时返回404
+func conflictError(err error, ifMatch bool) error {
+	if errors.Is(err, aquadao.DeletedConflictError) {
+		return serviceutil.NewStatusError(http.StatusConflict, err)
+	}
+	var conflict *aquadao.ConflictError
+	if !errors.As(err, &conflict) {
+		return notFoundError(err)
+	}
+	if ifMatch {
+		return serviceutil.NewStatusError(http.StatusPreconditionFailed, err)
+	}
+	return serviceutil.NewStatusError(http.StatusConflict, err)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type versionedNote struct {
+	domain.Model
+	domain.Versioning
+	Name string `json:"name" gorm:"column:name"`
+}
+
+// newVersionedServer 只注册 versionedNote 的接口，不经过认证
+func newVersionedServer(t *testing.T) (*gin.Engine, *aquadao.BaseDAO) {
+	t.Helper()
+	_, dao := newTestServer(t)
+	if err := dao.Session().AutoMigrate(&versionedNote{}); err != nil {
+		t.Fatal(err)
+	}
+	domain.RegisterModel(versionedNote{})
+	domain.DomainPath["versionedNote"] = "note"
+	t.Cleanup(func() { delete(domain.DomainPath, "versionedNote") })
+	h, err := NewResourceHandler[*versionedNote](dao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	h.RegisterTo(engine.Group("/api"))
+	if err = dao.Create(context.Background(), &versionedNote{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	return engine, dao
+}
+
+func TestVersionedUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		body    string
+		want    int
+		version uint
+	}{
+		{"patch without version", http.MethodPatch, "", `{"name":"b"}`, http.StatusPreconditionRequired, 1},
+		{"put without version", http.MethodPut, "", `{"name":"b"}`, http.StatusPreconditionRequired, 1},
+		{"stale body version", http.MethodPatch, "", `{"name":"b","version":2}`, http.StatusConflict, 1},
+		{"stale if-match", http.MethodPatch, `"v2"`, `{"name":"b"}`, http.StatusPreconditionFailed, 1},
+		{"stale if-match put", http.MethodPut, `"v2"`, `{"name":"b"}`, http.StatusPreconditionFailed, 1},
+		{"body version", http.MethodPatch, "", `{"name":"b","version":1}`, http.StatusOK, 2},
+		{"if-match", http.MethodPut, `"v1"`, `{"name":"b"}`, http.StatusOK, 2},
+		// "*" 明确不检查版本
+		{"if-match any", http.MethodPatch, "*", `{"name":"b"}`, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, dao := newVersionedServer(t)
+			req := httptest.NewRequest(tt.method, "/api/note/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if len(tt.ifMatch) > 0 {
+				req.Header.Set(headerIfMatch, tt.ifMatch)
+			}
+			w := serve(engine, req)
+			if w.Code != tt.want {
+				t.Fatalf("%s = %d, want %d, %s", tt.name, w.Code, tt.want, w.Body.String())
+			}
+			found := &versionedNote{Model: domain.Model{ID: 1}}
+			if err := dao.Get(context.Background(), found); err != nil {
+				t.Fatal(err)
+			}
+			if found.Version != tt.version {
+				t.Fatalf("version = %d, want %d", found.Version, tt.version)
+			}
+			if tt.want == http.StatusOK && w.Header().Get(headerETag) != `"v2"` {
+				t.Fatalf("etag = %s, want \"v2\"", w.Header().Get(headerETag))
+			}
+		})
+	}
+}
+
+// TestInvalidCursorRequest 非法的游标返回400
+func TestInvalidCursorRequest(t *testing.T) {
+	engine, _ := newVersionedServer(t)
+	w := serve(engine, httptest.NewRequest(http.MethodGet, "/api/note?page=1&page_size=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list = %d, %s", w.Code, w.Body.String())
+	}
+	rsp := struct {
+		Data struct {
+			NextCursor string `json:"next_cursor"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Data.NextCursor) == 0 {
+		t.Fatalf("full page should return next cursor, %s", w.Body.String())
+	}
+	tests := map[string]string{
+		"tampered":       "cursor=" + url.QueryEscape(rsp.Data.NextCursor+"x"),
+		"different sort": "cursor=" + url.QueryEscape(rsp.Data.NextCursor) + "&sort=-id",
+		"malformed":      "cursor=bad",
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := serve(engine, httptest.NewRequest(http.MethodGet, "/api/note?page=1&page_size=1&"+query, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("list with %s cursor = %d, want %d", name, w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
 	"github.com/MoWan-inc/aqua/pkg/config"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
 	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
 	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/gin-contrib/cors"
@@ -16,20 +19,38 @@ import (
 )
 
 func NewServer(injector *do.Injector, config *config.ApiConfig) (*gin.Engine, error) {
+	auth, err := authHandlers(config)
+	if err != nil {
+		return nil, err
+	}
+	api.SetCursorSecret(config.CursorSecret)
 	// new engin
 	engine := newServer(config)
 
-	logger := log.GetDefaultLogger()
-	baseLogger := logger.Base().WithOptions(zap.AddCallerSkip(-1))
-	engine.Use(ginzap.Ginzap(baseLogger, time.RFC3339, true))
-	engine.Use(ginzap.RecoveryWithZap(baseLogger, true))
+	// token 和 jwt 认证，中间件需要在注册路由之前添加
+	engine.Use(auth...)
+	// 认证之后在请求日志中记录用户
+	engine.Use(serviceutil.RequestUserLogger())
 
-	// token
-	engine.Use(serviceutil.TokenAuthentication(
-		serviceutil.GetTokenAuth(config.Tokens, []string{}))) // 白名单为空
+	dao, err := do.Invoke[aquadao.DAO](injector)
+	if err != nil {
+		return nil, err
+	}
+	handlers, err := resourceHandlers(dao)
+	if err != nil {
+		return nil, err
+	}
+	authorizer := newAuthorizer(injector, dao)
+	registerAdminHandler(engine, authorizer)
+	handlers = append(handlers, &meHandler{authorizer: authorizer})
 
 	groupAPI := getGroupAPI(engine, config)
-	handlers := []serviceutil.APIHandler{}
+	if config.RateLimit != nil {
+		groupAPI.Use(serviceutil.TokenLimit(newLimiter(injector, config.RateLimit), config.RateLimit))
+	}
+	// 认证中间件在没有凭证时不拦截，业务接口必须登录，未启用 RBAC 时也不允许匿名访问
+	groupAPI.Use(serviceutil.RequireUser())
+	groupAPI.Use(serviceutil.RBAC(authorizer, resources()))
 	for _, h := range handlers {
 		h.RegisterTo(groupAPI)
 	}
@@ -37,12 +58,68 @@ func NewServer(injector *do.Injector, config *config.ApiConfig) (*gin.Engine, er
 	return engine, nil
 }
 
+// resourceHandlers 新增模型时在 domain.DomainPath 注册路由，并在此添加对应的通用接口
+func resourceHandlers(dao aquadao.DAO) ([]serviceutil.APIHandler, error) {
+	template, err := NewResourceHandler[*domain.Template](dao)
+	if err != nil {
+		return nil, err
+	}
+	role, err := NewResourceHandler[*domain.Role](dao)
+	if err != nil {
+		return nil, err
+	}
+	return []serviceutil.APIHandler{template, role}, nil
+}
+
+// authHandlers 认证中间件，query token签名和 JWT bearer 可以同时使用
+func authHandlers(config *config.ApiConfig) (gin.HandlersChain, error) {
+	auth := gin.HandlersChain{
+		serviceutil.TokenAuthentication(
+			serviceutil.GetTokenAuth(config.Tokens, []string{}), // 白名单为空
+			serviceutil.NewSignVerifier(config.Sign)),
+	}
+	if config.JWT != nil {
+		jwtAuth, err := serviceutil.NewJWTAuth(config.JWT, []string{})
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, serviceutil.BearerAuthentication(jwtAuth))
+	}
+	return auth, nil
+}
+
+// newLimiter 配置了共享存储时多副本共享额度，存储不可用时使用进程内限流
+func newLimiter(injector *do.Injector, cfg *config.RateLimitConfig) serviceutil.Limiter {
+	local := serviceutil.NewMemoryLimiter(time.Duration(cfg.IdleSeconds) * time.Second)
+	if cfg.Store == nil {
+		return local
+	}
+	limiter := serviceutil.NewRedisLimiter(cfg.Store, local)
+	// 退出时由 injector 关闭连接
+	do.ProvideValue(injector, limiter)
+	return limiter
+}
+
+// resources domain.DomainPath 中注册的资源路由
+func resources() map[string]any {
+	res := make(map[string]any, len(domain.DomainPath))
+	for _, path := range domain.DomainPath {
+		res[path] = struct{}{}
+	}
+	return res
+}
+
 func newServer(config *config.ApiConfig) *gin.Engine {
 	engine := gin.Default()
+	// gin.Context 作为 context.Context 使用时可以取到 request 中的值，例如请求日志
+	engine.ContextWithFallback = true
 	logger := log.GetDefaultLogger()
 	baseLogger := logger.Base().WithOptions(zap.AddCallerSkip(-1))
 
+	// 请求日志最先注册，所有请求（包括认证失败和 /admin）都有 request id
 	middleWares := gin.HandlersChain{
+		serviceutil.RequestLogger(),
+		ginzap.Ginzap(baseLogger, time.RFC3339, true),
 		ginzap.RecoveryWithZap(baseLogger, true),
 		location.Default(),
 		corsMiddleware(engine),
@@ -61,13 +138,10 @@ func corsMiddleware(_ *gin.Engine) gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"PUT", "PATCH", "DELETE", "POST", "GET"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", serviceutil.RequestIDHeader, headerIfMatch},
+		ExposeHeaders:    []string{"Content-Length", serviceutil.RequestIDHeader, headerETag},
 		AllowCredentials: true, // enable cookie
-		AllowOriginFunc: func(origin string) bool {
-			return true
-		},
-		MaxAge: 12 * time.Hour,
+		MaxAge:           12 * time.Hour,
 	})
 }
 
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/do"
+	"net/http"
+	"sort"
+)
+
+var rbacVerbs = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// meHandler 当前用户信息，用于前端根据权限隐藏操作
+type meHandler struct {
+	authorizer *serviceutil.Authorizer
+}
+
+type permissionsResponse struct {
+	Principal *serviceutil.Principal `json:"principal"`
+	Roles     []string               `json:"roles"`
+	// 资源对应允许的HTTP方法
+	Permissions map[string][]string `json:"permissions"`
+}
+
+func (h *meHandler) RegisterTo(group *gin.RouterGroup) {
+	group.GET("/me/permissions", serviceutil.HandleAPIWithLimiter(h.permissions))
+}
+
+func (h *meHandler) permissions(c *gin.Context) (any, error) {
+	p, _ := serviceutil.GetPrincipal(c)
+	rsp := &permissionsResponse{Principal: p, Permissions: map[string][]string{}}
+	resources := make([]string, 0, len(domain.DomainPath))
+	for _, resource := range domain.DomainPath {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	// 未启用权限控制时允许所有操作
+	if !h.authorizer.Enabled() {
+		for _, resource := range resources {
+			rsp.Permissions[resource] = rbacVerbs
+		}
+		return rsp, nil
+	}
+	rsp.Roles = h.authorizer.Roles(p)
+	perms, err := h.authorizer.Permissions(c, p)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range resources {
+		verbs := make([]string, 0)
+		for _, verb := range rbacVerbs {
+			if serviceutil.Allowed(perms, resource, verb) {
+				verbs = append(verbs, verb)
+			}
+		}
+		rsp.Permissions[resource] = verbs
+	}
+	return rsp, nil
+}
+
+// daoRoleProvider 从数据库 domain.Role 中读取角色权限
+type daoRoleProvider struct {
+	dao aquadao.DAO
+}
+
+func (p *daoRoleProvider) Permissions(ctx context.Context, role string) ([]domain.Permission, error) {
+	r := &domain.Role{Name: role}
+	if err := p.dao.Get(ctx, r); err != nil {
+		if errors.Is(err, aquadao.NotExistsError) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.Permissions, nil
+}
+
+// newAuthorizer 未提供权限配置时不做权限控制
+func newAuthorizer(injector *do.Injector, dao aquadao.DAO) *serviceutil.Authorizer {
+	cfg, err := do.Invoke[*config.RBACConfig](injector)
+	if err != nil || cfg == nil {
+		log.Infof("rbac config not provided, rbac disabled")
+		return nil
+	}
+	var providers []serviceutil.RoleProvider
+	if cfg.LoadFromDB {
+		providers = append(providers, &daoRoleProvider{dao: dao})
+	}
+	return serviceutil.NewAuthorizer(cfg, providers...)
+}
@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/api"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
+	"github.com/MoWan-inc/aqua/pkg/util/object"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+var _ serviceutil.APIHandler = &ResourceHandler[*domain.Template]{}
+
+// ResourceHandler 通用的增删改查接口，路由由 domain.DomainPath 决定
+// T 为 domain 模型的指针类型，例如 *domain.Template
+type ResourceHandler[T domain.Indexer] struct {
+	dao  aquadao.DAO
+	path string
+}
+
+// NewResourceHandler 模型未在 domain.DomainPath 注册时返回错误
+func NewResourceHandler[T domain.Indexer](dao aquadao.DAO) (*ResourceHandler[T], error) {
+	cls := object.ClassName(object.NewObject[T]())
+	path, ok := domain.DomainPath[cls]
+	if !ok {
+		return nil, fmt.Errorf("resource handler error, %s not registered in domain path", cls)
+	}
+	return &ResourceHandler[T]{dao: dao, path: path}, nil
+}
+
+func (h *ResourceHandler[T]) RegisterTo(group *gin.RouterGroup) {
+	g := group.Group(h.path)
+	g.GET("", serviceutil.DefaultHandlers(h.List))
+	g.POST("", serviceutil.DefaultHandlers(h.Create))
+	g.GET("/aggregate", serviceutil.DefaultHandlers(h.Aggregate))
+	g.GET("/trash", serviceutil.DefaultHandlers(h.Trash))
+	g.GET("/:id", serviceutil.DefaultHandlers(h.Get))
+	g.PUT("/:id", serviceutil.DefaultHandlers(h.Save))
+	g.PATCH("/:id", serviceutil.DefaultHandlers(h.Update))
+	g.DELETE("/:id", serviceutil.DefaultHandlers(h.Delete))
+	g.POST("/:id/restore", serviceutil.DefaultHandlers(h.Restore))
+}
+
+// List 返回数据与 api.BaseListResponse 结构一致
+func (h *ResourceHandler[T]) List(c *gin.Context) (any, error) {
+	return h.list(c, false)
+}
+
+// Trash 已软删除的数据，参数与 List 相同
+func (h *ResourceHandler[T]) Trash(c *gin.Context) (any, error) {
+	return h.list(c, true)
+}
+
+func (h *ResourceHandler[T]) list(c *gin.Context, deleted bool) (any, error) {
+	q, err := bindQueryRequest[T](c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	var countOpts []aquadao.OptionFunc
+	listFunc := h.dao.List
+	if deleted {
+		countOpts = append(countOpts, aquadao.DeletedOnlyOption())
+		listFunc = h.dao.ListDeleted
+	}
+	total, err := h.dao.Count(c.Request.Context(), q, countOpts...)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	if err = listFunc(c.Request.Context(), q, &list); err != nil {
+		if errors.Is(err, api.InvalidCursorError) {
+			return nil, serviceutil.NewRequestError(err)
+		}
+		return nil, err
+	}
+	if columns := q.Columns(); len(columns) > 0 {
+		selected, err := selectFields(list, columns)
+		if err != nil {
+			return nil, err
+		}
+		rsp := &api.BaseListResponse[map[string]json.RawMessage]{}
+		rsp.Data.Total = int(total)
+		rsp.Data.List = selected
+		rsp.Data.NextCursor = q.NextCursor
+		return rsp.Data, nil
+	}
+	rsp := &api.BaseListResponse[T]{}
+	rsp.Data.Total = int(total)
+	rsp.Data.List = list
+	rsp.Data.NextCursor = q.NextCursor
+	return rsp.Data, nil
+}
+
+// Aggregate 按 group_by 分组计算 metrics，过滤参数与 List 相同
+func (h *ResourceHandler[T]) Aggregate(c *gin.Context) (any, error) {
+	q, err := bindQueryRequest[T](c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	req := api.AggregateRequest{}
+	if err = c.ShouldBindWith(&req, binding.Query); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	agg := req.Parse()
+	if err = agg.Validate(domain.GetGormFields(object.ClassName(q.Query))); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	rows, err := h.dao.Aggregate(c.Request.Context(), q, agg)
+	if errors.Is(err, aquadao.TooManyGroupsError) {
+		return nil, serviceutil.NewRequestError(fmt.Errorf("%w, narrow the filter or use page_size", aquadao.TooManyGroupsError))
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsp := &api.BaseListResponse[map[string]any]{}
+	rsp.Data.Total = len(rows)
+	rsp.Data.List = rows
+	return rsp.Data, nil
+}
+
+func (h *ResourceHandler[T]) Get(c *gin.Context) (any, error) {
+	obj, err := newWithParamID[T](c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	fieldset := api.Fieldset{}
+	if err = c.ShouldBindWith(&fieldset, binding.Query); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	columns := fieldset.Columns()
+	if err = api.ValidateColumns(domain.GetGormFields(object.ClassName(obj)), columns); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	if len(columns) == 0 {
+		if err = h.dao.Get(c.Request.Context(), obj); err != nil {
+			return nil, notFoundError(err)
+		}
+		setETag(c, obj)
+		return obj, nil
+	}
+	if err = h.dao.Get(c.Request.Context(), obj, aquadao.SelectOption(obj, columns...)); err != nil {
+		return nil, notFoundError(err)
+	}
+	selected, err := selectFields([]T{obj}, columns)
+	if err != nil {
+		return nil, err
+	}
+	return selected[0], nil
+}
+
+func (h *ResourceHandler[T]) Create(c *gin.Context) (any, error) {
+	obj := object.NewObject[T]()
+	if err := bindSaveRequest(c, obj); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	if err := h.dao.Create(c.Request.Context(), obj); err != nil {
+		return nil, err
+	}
+	setETag(c, obj)
+	return obj, nil
+}
+
+// Save 覆盖式更新
+func (h *ResourceHandler[T]) Save(c *gin.Context) (any, error) {
+	obj, err := h.bindWithParamID(c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	opts, ifMatch, err := ifMatchOptions(c, obj)
+	if err != nil {
+		return nil, serviceutil.NewStatusError(http.StatusPreconditionFailed, err)
+	}
+	if err = requireVersion(c, obj); err != nil {
+		return nil, err
+	}
+	if err = h.dao.Save(c.Request.Context(), obj, opts...); err != nil {
+		return nil, conflictError(err, ifMatch)
+	}
+	setETag(c, obj)
+	return obj, nil
+}
+
+// Update 只更新非空字段
+func (h *ResourceHandler[T]) Update(c *gin.Context) (any, error) {
+	obj, err := h.bindWithParamID(c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	opts, ifMatch, err := ifMatchOptions(c, obj)
+	if err != nil {
+		return nil, serviceutil.NewStatusError(http.StatusPreconditionFailed, err)
+	}
+	if err = requireVersion(c, obj); err != nil {
+		return nil, err
+	}
+	if err = h.dao.Update(c.Request.Context(), obj, opts...); err != nil {
+		return nil, conflictError(err, ifMatch)
+	}
+	setETag(c, obj)
+	return obj, nil
+}
+
+func (h *ResourceHandler[T]) Delete(c *gin.Context) (any, error) {
+	obj, err := newWithParamID[T](c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	if err = api.DeleteFor(obj).Validate(); err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	affected, err := h.dao.Delete(c.Request.Context(), obj)
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, notFoundError(aquadao.NotExistsError)
+	}
+	return obj, nil
+}
+
+// Restore 恢复软删除的数据
+func (h *ResourceHandler[T]) Restore(c *gin.Context) (any, error) {
+	obj, err := newWithParamID[T](c)
+	if err != nil {
+		return nil, serviceutil.NewRequestError(err)
+	}
+	affected, err := h.dao.Restore(c.Request.Context(), obj)
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, notFoundError(aquadao.NotExistsError)
+	}
+	// 刚恢复的数据可能还没有同步到副本
+	if err = h.dao.Get(c.Request.Context(), obj, aquadao.PrimaryOption()); err != nil {
+		return nil, notFoundError(err)
+	}
+	setETag(c, obj)
+	return obj, nil
+}
+
+// notFoundError 数据不存在时返回404
+func notFoundError(err error) error {
+	if errors.Is(err, aquadao.NotExistsError) {
+		return serviceutil.NewStatusError(http.StatusNotFound, err)
+	}
+	return err
+}
+
+func (h *ResourceHandler[T]) bindWithParamID(c *gin.Context) (T, error) {
+	obj, err := newWithParamID[T](c)
+	if err != nil {
+		return obj, err
+	}
+	if err = bindSaveRequest(c, obj); err != nil {
+		return obj, err
+	}
+	return obj, nil
+}
+
+func newWithParamID[T domain.Indexer](c *gin.Context) (T, error) {
+	obj := object.NewObject[T]()
+	id, err := serviceutil.ParseParamID(c)
+	if err != nil {
+		return obj, err
+	}
+	if err = obj.SetKey(id); err != nil {
+		return obj, err
+	}
+	return obj, nil
+}
+
+func bindSaveRequest(c *gin.Context, obj domain.Indexer) error {
+	key := obj.Key()
+	if err := c.ShouldBindWith(obj, binding.JSON); err != nil {
+		return err
+	}
+	// 以路径参数中的id为准，body 中的 id 不生效
+	if err := obj.SetKey(key); err != nil {
+		return err
+	}
+	return api.SaveFor(obj).Validate()
+}
+
+// bindQueryRequest query 和 not 参数为 json 格式的模型对象，其余为分页、排序、过滤参数
+func bindQueryRequest[T domain.Indexer](c *gin.Context) (*api.QueryRequest, error) {
+	query := object.NewObject[T]()
+	not := object.NewObject[T]()
+	if s := c.Query("query"); s != "" {
+		if err := json.Unmarshal([]byte(s), query); err != nil {
+			return nil, fmt.Errorf("query param error: %w", err)
+		}
+	}
+	if s := c.Query("not"); s != "" {
+		if err := json.Unmarshal([]byte(s), not); err != nil {
+			return nil, fmt.Errorf("not param error: %w", err)
+		}
+	}
+	q := &api.QueryRequest{Query: query, Not: not}
+	if err := c.ShouldBindWith(&q.Pagination, binding.Query); err != nil {
+		return nil, err
+	}
+	if err := c.ShouldBindWith(&q.Sorting, binding.Query); err != nil {
+		return nil, err
+	}
+	if err := c.ShouldBindWith(&q.Filter, binding.Query); err != nil {
+		return nil, err
+	}
+	if err := c.ShouldBindWith(&q.Fieldset, binding.Query); err != nil {
+		return nil, err
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// selectFields 只保留指定列对应的json字段，未查询的列不会以零值返回
+func selectFields[T domain.Indexer](items []T, columns []string) ([]map[string]json.RawMessage, error) {
+	jsonFields := domain.GetJSONFields(object.ClassName(object.NewObject[T]()))
+	results := make([]map[string]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		all := map[string]json.RawMessage{}
+		if err = json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		selected := make(map[string]json.RawMessage, len(columns))
+		for _, c := range columns {
+			name := jsonFields[c]
+			if v, ok := all[name]; ok {
+				selected[name] = v
+			}
+		}
+		results = append(results, selected)
+	}
+	return results, nil
+}
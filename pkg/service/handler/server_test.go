@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	serviceutil "github.com/MoWan-inc/aqua/pkg/service/util"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/samber/do"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testToken  = "tk"
+	testSecret = "secret"
+)
+
+var testNonce atomic.Int64
+
+// newTestServer 使用内存数据库，token 使用 v2 签名
+func newTestServer(t *testing.T) (*gin.Engine, *aquadao.BaseDAO) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = db.AutoMigrate(&domain.Template{}, &domain.Role{}); err != nil {
+		t.Fatal(err)
+	}
+	dao := aquadao.NewBaseDAO(db)
+	injector := do.New()
+	do.ProvideValue[aquadao.DAO](injector, dao)
+	cfg := config.DefaultApiConfig()
+	cfg.Tokens = []string{testToken}
+	cfg.Sign.Secrets = map[string]string{testToken: testSecret}
+	engine, err := NewServer(injector, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, dao
+}
+
+// signedRequest 带 v2 签名的请求，签名内容见 serviceutil.SignVerifier
+func signedRequest(method, path, body string) *http.Request {
+	query := url.Values{}
+	query.Set("token", testToken)
+	query.Set("timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	query.Set("nonce", strconv.FormatInt(testNonce.Add(1), 10))
+	query.Set("sign_version", serviceutil.SignVersionHMAC)
+	bodyHash := sha256.Sum256([]byte(body))
+	content := strings.Join([]string{
+		serviceutil.SignVersionHMAC, method, path, query.Encode(), hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	query.Set("sign", serviceutil.HMACSign(testSecret, content))
+	req := httptest.NewRequest(method, path+"?"+query.Encode(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func serve(engine *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// TestAnonymousRequest 未启用 RBAC 时业务接口也需要认证
+func TestAnonymousRequest(t *testing.T) {
+	engine, dao := newTestServer(t)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/api/v1/role", `{"name":"admin"}`},
+		{http.MethodPost, "/api/v1/template", `{"name":"t"}`},
+		{http.MethodGet, "/api/v1/template", ""},
+		{http.MethodDelete, "/api/v1/role/1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if w := serve(engine, req); w.Code != http.StatusUnauthorized {
+				t.Fatalf("anonymous %s %s = %d, want %d", tt.method, tt.path, w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+	var roles []domain.Role
+	if err := dao.Session().Find(&roles).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 0 {
+		t.Fatalf("anonymous post created %d roles", len(roles))
+	}
+
+	w := serve(engine, signedRequest(http.MethodPost, "/api/v1/role", `{"name":"admin"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("authenticated post = %d, %s", w.Code, w.Body.String())
+	}
+}
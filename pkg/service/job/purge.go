@@ -0,0 +1,61 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"time"
+)
+
+// PurgeJob 定时永久删除软删除超过保留天数的数据，多副本同时执行也不影响结果
+type PurgeJob struct {
+	dao aquadao.DAO
+	cfg *config.PurgeConfig
+	now func() time.Time
+}
+
+func NewPurgeJob(dao aquadao.DAO, cfg *config.PurgeConfig) *PurgeJob {
+	return &PurgeJob{dao: dao, cfg: cfg, now: time.Now}
+}
+
+// Run 启动时执行一次，之后按间隔执行，ctx 结束时退出
+func (j *PurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.cfg.GetInterval()) * time.Second)
+	defer ticker.Stop()
+	for {
+		j.PurgeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce 清理所有配置的资源，单个资源失败不影响其他资源
+func (j *PurgeJob) PurgeOnce(ctx context.Context) {
+	for resource, days := range j.cfg.RetentionDays {
+		if err := j.purge(ctx, resource, days); err != nil {
+			log.FromContext(ctx).Errorw("purge deleted error", "resource", resource, "error", err)
+		}
+	}
+}
+
+func (j *PurgeJob) purge(ctx context.Context, resource string, days int) error {
+	model, ok := domain.NewModelOfPath(resource)
+	if !ok {
+		return fmt.Errorf("purge error, unknown resource %s", resource)
+	}
+	olderThan := j.now().AddDate(0, 0, -days)
+	affected, err := j.dao.Purge(ctx, model, olderThan)
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		log.FromContext(ctx).Infow("purge deleted", "resource", resource, "older_than", olderThan, "rows", affected)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+	aquadao "github.com/MoWan-inc/aqua/pkg/dao/gorm"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDAO(t *testing.T) *aquadao.BaseDAO {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = db.AutoMigrate(&domain.Template{}, &domain.Role{}); err != nil {
+		t.Fatal(err)
+	}
+	return aquadao.NewBaseDAO(db)
+}
+
+// deleteAt 软删除并修改删除时间
+func deleteAt(t *testing.T, dao *aquadao.BaseDAO, obj domain.Indexer, deletedAt time.Time) {
+	t.Helper()
+	if err := dao.Create(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	err := dao.Session().Unscoped().Model(obj).Update("deleted_at", deletedAt).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unscopedNames(t *testing.T, dao *aquadao.BaseDAO, model any) []string {
+	t.Helper()
+	var names []string
+	if err := dao.Session().Unscoped().Model(model).Order("name").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// TestPurgeOnce 按每个资源的保留天数清理，未知的资源不影响其他资源
+func TestPurgeOnce(t *testing.T) {
+	dao := newTestDAO(t)
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	deleteAt(t, dao, &domain.Template{Name: "expired"}, now.AddDate(0, 0, -8))
+	deleteAt(t, dao, &domain.Template{Name: "kept"}, now.AddDate(0, 0, -6))
+	deleteAt(t, dao, &domain.Role{Name: "expired"}, now.AddDate(0, 0, -2))
+	deleteAt(t, dao, &domain.Role{Name: "kept"}, now.AddDate(0, 0, -1).Add(time.Minute))
+	if err := dao.Create(context.Background(), &domain.Template{Name: "live"}); err != nil {
+		t.Fatal(err)
+	}
+
+	job := NewPurgeJob(dao, &config.PurgeConfig{RetentionDays: map[string]int{"template": 7, "role": 1, "unknown": 1}})
+	job.now = func() time.Time { return now }
+	job.PurgeOnce(context.Background())
+
+	if names := unscopedNames(t, dao, &domain.Template{}); !reflect.DeepEqual(names, []string{"kept", "live"}) {
+		t.Fatalf("templates after purge = %v, want [kept live]", names)
+	}
+	if names := unscopedNames(t, dao, &domain.Role{}); !reflect.DeepEqual(names, []string{"kept"}) {
+		t.Fatalf("roles after purge = %v, want [kept]", names)
+	}
+	if err := job.purge(context.Background(), "unknown", 1); err == nil {
+		t.Fatal("purge unknown resource should fail")
+	}
+}
+
+// TestPurgeRun 启动时立即清理一次，ctx 结束后退出
+func TestPurgeRun(t *testing.T) {
+	dao := newTestDAO(t)
+	deleteAt(t, dao, &domain.Template{Name: "expired"}, time.Now().AddDate(0, 0, -2))
+	job := NewPurgeJob(dao, &config.PurgeConfig{RetentionDays: map[string]int{"template": 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		job.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(unscopedNames(t, dao, &domain.Template{})) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("run should purge on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run should return after context is done")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/MoWan-inc/aqua/pkg/util/object"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -46,12 +47,18 @@ func HandleAPIWithLimiter(handler GinServerHandler) gin.HandlerFunc {
 		if err == nil {
 			JSONSuccess(c, data)
 		} else {
-			if errors.As(err, &RequestError{}) {
+			var reqErr *RequestError
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) {
+				log.FromContext(c).Warnw("request error", "status", statusErr.Status, "error", err)
+				c.AbortWithStatusJSON(statusErr.Status, gin.H{"msg": err.Error()})
+			} else if errors.As(err, &reqErr) {
+				log.FromContext(c).Warnw("request error", "error", err)
 				JSONRequestError(c, err)
 			} else {
+				log.FromContext(c).Errorw("internal error", "error", err)
 				JSONInternalError(c, err)
 			}
-			// todo 日志
 		}
 	}
 }
@@ -80,6 +87,20 @@ type InternalError struct {
 	error
 }
 
+// StatusError 指定HTTP状态码的错误，例如 409、412
+type StatusError struct {
+	Status int
+	error
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.error
+}
+
+func NewStatusError(status int, err error) *StatusError {
+	return &StatusError{Status: status, error: err}
+}
+
 type HTTPRsp[T any] struct {
 	Data T      `json:"data"`
 	Msg  string `json:"msg"`
@@ -0,0 +1,251 @@
+package util
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	bearerPrefix      = "Bearer "
+	defaultNameClaim  = "name"
+	defaultRolesClaim = "roles"
+	// 配置文件中直接配置的密钥，不区分 kid
+	defaultKeyID = ""
+)
+
+// BearerAuthentication 从 Authorization: Bearer 中读取token认证，未携带时交给其他认证方式
+// 可以和 TokenAuthentication 同时使用
+func BearerAuthentication(auth TokenAuth) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, ok := getBearerToken(ctx)
+		if !ok {
+			return
+		}
+		if !auth.Need(ctx) {
+			return
+		}
+		if !auth.CheckToken(ctx, token) {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"msg": "invalid bearer token"})
+			return
+		}
+	}
+}
+
+func getBearerToken(ctx *gin.Context) (string, bool) {
+	header := ctx.GetHeader("Authorization")
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):]), true
+}
+
+var _ TokenAuth = &jwtAuth{}
+
+// jwtAuth 校验 HS256/RS256 签名的JWT，并将claim映射为 Principal
+type jwtAuth struct {
+	whiteListURL map[string]any
+	hmacKeys     map[string][]byte
+	rsaKeys      map[string]*rsa.PublicKey
+	parser       *jwt.Parser
+	nameClaim    string
+	rolesClaim   string
+}
+
+func NewJWTAuth(cfg *config.JWTConfig, whiteList []string) (TokenAuth, error) {
+	auth := &jwtAuth{
+		whiteListURL: make(map[string]any),
+		hmacKeys:     make(map[string][]byte),
+		rsaKeys:      make(map[string]*rsa.PublicKey),
+		nameClaim:    cfg.NameClaim,
+		rolesClaim:   cfg.RolesClaim,
+	}
+	for _, wl := range whiteList {
+		auth.whiteListURL[wl] = struct{}{}
+	}
+	if len(auth.nameClaim) == 0 {
+		auth.nameClaim = defaultNameClaim
+	}
+	if len(auth.rolesClaim) == 0 {
+		auth.rolesClaim = defaultRolesClaim
+	}
+	if len(cfg.HMACSecret) > 0 {
+		auth.hmacKeys[defaultKeyID] = []byte(cfg.HMACSecret)
+	}
+	if len(cfg.RSAPublicKeyPath) > 0 {
+		content, err := os.ReadFile(cfg.RSAPublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt rsa public key error: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(content)
+		if err != nil {
+			return nil, fmt.Errorf("parse jwt rsa public key error: %w", err)
+		}
+		auth.rsaKeys[defaultKeyID] = key
+	}
+	if len(cfg.JWKSPath) > 0 {
+		if err := auth.loadJWKS(cfg.JWKSPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(auth.hmacKeys) == 0 && len(auth.rsaKeys) == 0 {
+		return nil, errors.New("jwt config error, no key configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Duration(cfg.LeewaySeconds) * time.Second),
+	}
+	if len(cfg.Issuer) > 0 {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if len(cfg.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	auth.parser = jwt.NewParser(opts...)
+	return auth, nil
+}
+
+func (a *jwtAuth) Need(ctx *gin.Context) bool {
+	_, has := a.whiteListURL[ctx.FullPath()]
+	return !has
+}
+
+func (a *jwtAuth) CheckToken(ctx *gin.Context, token string) bool {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFunc); err != nil {
+		log.FromContext(ctx).Warnw("jwt verify failed", "error", err)
+		return false
+	}
+	principal, err := a.principal(claims)
+	if err != nil {
+		log.FromContext(ctx).Warnw("jwt claims invalid", "error", err)
+		return false
+	}
+	ctx.Set(UsrKey, principal)
+	return true
+}
+
+func (a *jwtAuth) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return selectKey(a.hmacKeys, kid)
+	case *jwt.SigningMethodRSA:
+		return selectKey(a.rsaKeys, kid)
+	default:
+		return nil, fmt.Errorf("unexpected jwt signing method %v", token.Header["alg"])
+	}
+}
+
+// selectKey 有 kid 时严格匹配，没有 kid 时使用配置的默认密钥或唯一的密钥
+func selectKey[T any](keys map[string]T, kid string) (T, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	var empty T
+	if len(kid) == 0 && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return empty, fmt.Errorf("jwt key %q not found", kid)
+}
+
+func (a *jwtAuth) principal(claims jwt.MapClaims) (*Principal, error) {
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	if len(sub) == 0 {
+		return nil, errors.New("jwt subject is required")
+	}
+	p := &Principal{Subject: sub, Name: sub, Scheme: AuthSchemeJWT}
+	if name, ok := claims[a.nameClaim].(string); ok && len(name) > 0 {
+		p.Name = name
+	}
+	// 角色支持数组或空格分隔的字符串
+	switch roles := claims[a.rolesClaim].(type) {
+	case string:
+		p.Roles = strings.Fields(roles)
+	case []any:
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	}
+	return p, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// oct
+	K string `json:"k"`
+}
+
+func (a *jwtAuth) loadJWKS(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read jwks error: %w", err)
+	}
+	jwks := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err = json.Unmarshal(content, &jwks); err != nil {
+		return fmt.Errorf("parse jwks error: %w", err)
+	}
+	for _, k := range jwks.Keys {
+		switch k.Kty {
+		case "RSA":
+			key, err := k.rsaPublicKey()
+			if err != nil {
+				return fmt.Errorf("parse jwks key %s error: %w", k.Kid, err)
+			}
+			a.rsaKeys[k.Kid] = key
+		case "oct":
+			key, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return fmt.Errorf("parse jwks key %s error: %w", k.Kid, err)
+			}
+			a.hmacKeys[k.Kid] = key
+		default:
+			// 其他类型的密钥不支持，忽略
+			log.Warnf("jwks key %s with type %s not supported", k.Kid, k.Kty)
+		}
+	}
+	return nil
+}
+
+func (k *jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
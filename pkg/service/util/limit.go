@@ -1,44 +1,186 @@
 package util
 
 import (
-	"flag"
+	"context"
 	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
-// create a map to hold the rate limiters for each visitor and a mutex
-var visitors = make(map[string]*rate.Limiter)
-var mu sync.Mutex
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
 
-var tokenLimit = flag.Float64("token-limit", 1, "http serve token rate limit")
-var tokenBursts = flag.Int("token-burst", 5, "http serve token burst")
+	defaultLimiterIdle = 10 * time.Minute
+)
+
+// LimitResult 限流结果，用于返回 RateLimit-* 响应头
+type LimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 令牌桶恢复满额的时间
+	Reset time.Duration
+	// 被限流时下次可以请求的时间
+	RetryAfter time.Duration
+}
+
+// Limiter 限流器，key 相同的请求共享额度
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy config.RatePolicy) (*LimitResult, error)
+}
+
+var _ Limiter = &MemoryLimiter{}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// MemoryLimiter 进程内令牌桶限流，空闲超过 idle 的记录在访问时清理
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	idle     time.Duration
+	visitors map[string]*visitor
+	lastGC   time.Time
+	now      func() time.Time
+}
 
-func getVisitorLimiter(token string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
+func NewMemoryLimiter(idle time.Duration) *MemoryLimiter {
+	if idle <= 0 {
+		idle = defaultLimiterIdle
+	}
+	return &MemoryLimiter{
+		idle:     idle,
+		visitors: make(map[string]*visitor),
+		now:      time.Now,
+	}
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, policy config.RatePolicy) (*LimitResult, error) {
+	now := m.now()
+	lim := m.getVisitorLimiter(key, policy, now)
+	allowed := lim.AllowN(now, 1)
+	return tokenBucketResult(allowed, lim.TokensAt(now), policy), nil
+}
 
-	limiter, ok := visitors[token]
-	if !ok {
-		limiter = rate.NewLimiter(rate.Limit(*tokenLimit), *tokenBursts)
-		visitors[token] = limiter
+func (m *MemoryLimiter) getVisitorLimiter(key string, policy config.RatePolicy, now time.Time) *rate.Limiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastGC) > m.idle {
+		for k, v := range m.visitors {
+			if now.Sub(v.lastSeen) > m.idle {
+				delete(m.visitors, k)
+			}
+		}
+		m.lastGC = now
+	}
+	v, ok := m.visitors[key]
+	// 策略变化时重新创建
+	if !ok || v.limiter.Limit() != rate.Limit(policy.Rate) || v.limiter.Burst() != policy.Burst {
+		v = &visitor{limiter: rate.NewLimiter(rate.Limit(policy.Rate), policy.Burst)}
+		m.visitors[key] = v
 	}
-	return limiter
+	v.lastSeen = now
+	return v.limiter
 }
 
-func TokenLimit(next GinServerHandler) GinServerHandler {
-	return func(ctx *gin.Context) (any, error) {
-		// 从请求中获取token，如果没有带token则按照默认限流处理
-		token, _ := getTokenFromCtx(ctx) //nolint:errcheck
+func tokenBucketResult(allowed bool, tokens float64, policy config.RatePolicy) *LimitResult {
+	result := &LimitResult{
+		Allowed:   allowed,
+		Limit:     policy.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(policy.Burst) - tokens) / policy.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / policy.Rate * float64(time.Second))
+	}
+	return result
+}
 
-		limiter := getVisitorLimiter(token.Token)
-		if !limiter.Allow() {
+// TokenLimit 按配置的策略限流，需要在认证之后使用以便按用户限流
+func TokenLimit(limiter Limiter, cfg *config.RateLimitConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity := limitIdentity(ctx)
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		policy := selectPolicy(cfg, identity, route)
+		if policy.Rate <= 0 {
+			return
+		}
+		key := limitKey(ctx, cfg, policy, identity, route)
+		result, err := limiter.Allow(ctx, key, policy)
+		if err != nil {
+			// 限流器异常时不影响请求
+			log.FromContext(ctx).Errorw("rate limiter error", "key", key, "error", err)
+			return
+		}
+		setRateLimitHeaders(ctx, result)
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			ctx.Header(HeaderRetryAfter, strconv.Itoa(retryAfter))
 			ctx.AbortWithStatusJSON(http.StatusTooManyRequests,
-				gin.H{"msg": fmt.Sprintf("too many requests for token %s", token.Token)})
-			return nil, nil
+				gin.H{"msg": fmt.Sprintf("too many requests, retry after %d seconds", retryAfter)})
+			return
+		}
+	}
+}
+
+// limitIdentity 认证用户，JWT 使用 subject，token 认证使用 token，未认证时为空
+func limitIdentity(ctx *gin.Context) string {
+	if p, ok := GetPrincipal(ctx); ok && p.Scheme == AuthSchemeJWT {
+		return p.Subject
+	}
+	if token, err := getTokenFromCtx(ctx); err == nil && len(token.Token) > 0 {
+		return token.Token
+	}
+	return ""
+}
+
+func selectPolicy(cfg *config.RateLimitConfig, identity, route string) config.RatePolicy {
+	if p, ok := cfg.Tokens[identity]; ok && len(identity) > 0 {
+		return p
+	}
+	if p, ok := cfg.Routes[route]; ok {
+		return p
+	}
+	return cfg.Default
+}
+
+// limitKey 路由单独配置的策略使用独立的额度，其余路由共享默认额度
+func limitKey(ctx *gin.Context, cfg *config.RateLimitConfig, policy config.RatePolicy, identity, route string) string {
+	scope := "default"
+	if _, ok := cfg.Routes[route]; ok {
+		scope = route
+	}
+	switch policy.By {
+	case config.RateLimitByRoute:
+		return "route:" + route
+	case config.RateLimitByIP:
+		return scope + "|ip:" + ctx.ClientIP()
+	default:
+		if len(identity) == 0 {
+			return scope + "|ip:" + ctx.ClientIP()
 		}
-		return next(ctx)
+		return scope + "|token:" + identity
 	}
 }
+
+func setRateLimitHeaders(ctx *gin.Context, result *LimitResult) {
+	ctx.Header(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	ctx.Header(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	ctx.Header(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
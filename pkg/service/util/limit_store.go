@@ -0,0 +1,148 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStoreTimeout = 100 * time.Millisecond
+	defaultStorePrefix  = "aqua:ratelimit:"
+	// 共享存储不可用后，这段时间内直接使用进程内限流，避免每个请求都等待超时
+	storeFailureCooldown = 5 * time.Second
+)
+
+/*
+slidingWindowScript 滑动窗口限流，窗口内的请求记录在有序集合中
+KEYS[1] 限流key
+ARGV[1] 当前时间（微秒） ARGV[2] 窗口大小（微秒） ARGV[3] 窗口内允许的请求数 ARGV[4] 请求唯一标识
+返回 {是否放行, 剩余次数, 需要等待的微秒数, 窗口内最早请求的时间}
+*/
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local oldest = now
+local first = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #first > 0 then
+	oldest = tonumber(first[2])
+end
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+	return {1, limit - count - 1, 0, oldest}
+end
+return {0, 0, oldest + window - now, oldest}
+`)
+
+var _ Limiter = &StoreLimiter{}
+
+/*
+StoreLimiter 多副本共享额度的滑动窗口限流，存储兼容Redis协议
+令牌桶策略转换为窗口：窗口大小为 Burst/Rate 秒，窗口内最多 Burst 个请求，长期速率与令牌桶一致
+共享存储不可用时退化为进程内限流
+*/
+type StoreLimiter struct {
+	client  redis.UniversalClient
+	prefix  string
+	timeout time.Duration
+	local   Limiter
+
+	mu       sync.Mutex
+	failedAt time.Time
+	now      func() time.Time
+}
+
+func NewStoreLimiter(client redis.UniversalClient, cfg *config.RateLimitStoreConfig, local Limiter) *StoreLimiter {
+	l := &StoreLimiter{
+		client:  client,
+		prefix:  cfg.Prefix,
+		timeout: time.Duration(cfg.TimeoutMillis) * time.Millisecond,
+		local:   local,
+		now:     time.Now,
+	}
+	if len(l.prefix) == 0 {
+		l.prefix = defaultStorePrefix
+	}
+	if l.timeout <= 0 {
+		l.timeout = defaultStoreTimeout
+	}
+	return l
+}
+
+// NewRedisLimiter 根据配置连接共享存储
+func NewRedisLimiter(cfg *config.RateLimitStoreConfig, local Limiter) *StoreLimiter {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return NewStoreLimiter(client, cfg, local)
+}
+
+func (s *StoreLimiter) Allow(ctx context.Context, key string, policy config.RatePolicy) (*LimitResult, error) {
+	if s.inCooldown() {
+		return s.local.Allow(ctx, key, policy)
+	}
+	result, err := s.allow(ctx, key, policy)
+	if err != nil {
+		log.FromContext(ctx).Warnw("rate limit store unavailable, fallback to local limiter", "error", err)
+		s.markFailed()
+		return s.local.Allow(ctx, key, policy)
+	}
+	return result, nil
+}
+
+func (s *StoreLimiter) allow(ctx context.Context, key string, policy config.RatePolicy) (*LimitResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := s.now()
+	window := time.Duration(float64(policy.Burst) / policy.Rate * float64(time.Second))
+	res, err := slidingWindowScript.Run(ctx, s.client, []string{s.prefix + key},
+		now.UnixMicro(), window.Microseconds(), policy.Burst, s.member(now)).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	oldest := time.UnixMicro(res[3])
+	return &LimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      policy.Burst,
+		Remaining:  int(res[1]),
+		Reset:      oldest.Add(window).Sub(now),
+		RetryAfter: time.Duration(res[2]) * time.Microsecond,
+	}, nil
+}
+
+// member 同一微秒内不同副本的请求也需要区分
+func (s *StoreLimiter) member(now time.Time) string {
+	return strconv.FormatInt(now.UnixMicro(), 10) + "-" + newRequestID()
+}
+
+func (s *StoreLimiter) inCooldown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.failedAt.IsZero() && s.now().Sub(s.failedAt) < storeFailureCooldown
+}
+
+func (s *StoreLimiter) markFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedAt = s.now()
+}
+
+// Shutdown 关闭存储连接
+func (s *StoreLimiter) Shutdown() error {
+	return s.client.Close()
+}
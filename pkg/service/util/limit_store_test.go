@@ -0,0 +1,150 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// countingLimiter 记录回退到进程内限流的次数，总是放行
+type countingLimiter struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (l *countingLimiter) Allow(_ context.Context, _ string, policy config.RatePolicy) (*LimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	return &LimitResult{Allowed: true, Limit: policy.Burst, Remaining: policy.Burst}, nil
+}
+
+func (l *countingLimiter) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestStoreLimiter(t *testing.T, addr string, clock *fakeClock) (*StoreLimiter, *countingLimiter) {
+	t.Helper()
+	local := &countingLimiter{}
+	l := NewRedisLimiter(&config.RateLimitStoreConfig{Addr: addr, TimeoutMillis: 200}, local)
+	l.now = clock.Now
+	t.Cleanup(func() { _ = l.Shutdown() })
+	return l, local
+}
+
+func allow(t *testing.T, l *StoreLimiter, policy config.RatePolicy) *LimitResult {
+	t.Helper()
+	result, err := l.Allow(context.Background(), "user", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// TestStoreLimiterWindow 窗口内最多 Burst 个请求，多个副本共享额度，窗口滑过后恢复
+func TestStoreLimiterWindow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	// 窗口为 3/1 = 3秒
+	policy := config.RatePolicy{Rate: 1, Burst: 3}
+	a, localA := newTestStoreLimiter(t, mr.Addr(), clock)
+	b, localB := newTestStoreLimiter(t, mr.Addr(), clock)
+
+	for i, l := range []*StoreLimiter{a, b, a} {
+		result := allow(t, l, policy)
+		if !result.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+		if result.Remaining != policy.Burst-i-1 {
+			t.Fatalf("request %d remaining = %d, want %d", i, result.Remaining, policy.Burst-i-1)
+		}
+		clock.Add(time.Second)
+	}
+	// 第一个请求在 3 秒前，提前一微秒时仍在窗口内
+	clock.Add(-time.Microsecond)
+	result := allow(t, b, policy)
+	if result.Allowed {
+		t.Fatal("request over burst should be denied")
+	}
+	if result.RetryAfter != time.Microsecond {
+		t.Fatalf("retry after = %v, want %v", result.RetryAfter, time.Microsecond)
+	}
+	// 第一个请求滑出窗口后放行一个
+	clock.Add(time.Microsecond)
+	if result = allow(t, a, policy); !result.Allowed {
+		t.Fatal("request after window expiry should be allowed")
+	}
+	if result = allow(t, b, policy); result.Allowed {
+		t.Fatal("second request after window expiry should be denied")
+	}
+	if localA.count() != 0 || localB.count() != 0 {
+		t.Fatalf("local limiter should not be used, calls %d, %d", localA.count(), localB.count())
+	}
+	// 整个窗口过去后恢复满额
+	clock.Add(3 * time.Second)
+	if result = allow(t, a, policy); !result.Allowed || result.Remaining != policy.Burst-1 {
+		t.Fatalf("request after full window = %+v, want allowed with remaining %d", result, policy.Burst-1)
+	}
+}
+
+// TestStoreLimiterFallback 存储出错时使用进程内限流，冷却时间内不再访问存储
+func TestStoreLimiterFallback(t *testing.T) {
+	mr := miniredis.RunT(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	policy := config.RatePolicy{Rate: 1, Burst: 1}
+	l, local := newTestStoreLimiter(t, mr.Addr(), clock)
+
+	if result := allow(t, l, policy); !result.Allowed {
+		t.Fatal("first request should be allowed")
+	}
+	if result := allow(t, l, policy); result.Allowed {
+		t.Fatal("request over burst should be denied")
+	}
+
+	mr.SetError("LOADING server is loading")
+	if result := allow(t, l, policy); !result.Allowed || local.count() != 1 {
+		t.Fatalf("request when store errors = %+v, local calls %d, want fallback", result, local.count())
+	}
+	// 存储恢复后冷却时间内仍使用进程内限流
+	mr.SetError("")
+	clock.Add(storeFailureCooldown - time.Millisecond)
+	if result := allow(t, l, policy); !result.Allowed || local.count() != 2 {
+		t.Fatalf("request in cooldown = %+v, local calls %d, want fallback", result, local.count())
+	}
+	clock.Add(time.Millisecond)
+	if result := allow(t, l, policy); !result.Allowed || local.count() != 2 {
+		t.Fatalf("request after cooldown = %+v, local calls %d, want store", result, local.count())
+	}
+	if result := allow(t, l, policy); result.Allowed {
+		t.Fatal("request over burst after cooldown should be denied by store")
+	}
+
+	// 存储不可用
+	mr.Close()
+	if result := allow(t, l, policy); !result.Allowed || local.count() != 3 {
+		t.Fatalf("request when store is down = %+v, local calls %d, want fallback", result, local.count())
+	}
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+)
+
+func newTestMemoryLimiter(idle time.Duration, clock *fakeClock) *MemoryLimiter {
+	l := NewMemoryLimiter(idle)
+	l.now = clock.Now
+	return l
+}
+
+func allowMemory(t *testing.T, l *MemoryLimiter, key string, policy config.RatePolicy) *LimitResult {
+	t.Helper()
+	result, err := l.Allow(context.Background(), key, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func visitorKeys(l *MemoryLimiter) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make([]string, 0, len(l.visitors))
+	for k := range l.visitors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestMemoryLimiterBurst 同一时刻最多 Burst 个请求，不同 key 的额度互不影响
+func TestMemoryLimiterBurst(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := newTestMemoryLimiter(time.Minute, clock)
+	policy := config.RatePolicy{Rate: 2, Burst: 3}
+	for i := 0; i < policy.Burst; i++ {
+		result := allowMemory(t, l, "a", policy)
+		if !result.Allowed || result.Limit != policy.Burst || result.Remaining != policy.Burst-i-1 {
+			t.Fatalf("request %d = %+v, want allowed with remaining %d", i, result, policy.Burst-i-1)
+		}
+	}
+	result := allowMemory(t, l, "a", policy)
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("request over burst = %+v, want denied", result)
+	}
+	// 每秒2个令牌，0.5秒后恢复一个，1.5秒后恢复满额
+	if result.RetryAfter != 500*time.Millisecond || result.Reset != 1500*time.Millisecond {
+		t.Fatalf("retry after = %v, reset = %v, want 500ms, 1.5s", result.RetryAfter, result.Reset)
+	}
+	if result = allowMemory(t, l, "b", policy); !result.Allowed || result.Remaining != policy.Burst-1 {
+		t.Fatalf("request of other key = %+v, want allowed", result)
+	}
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := newTestMemoryLimiter(time.Minute, clock)
+	policy := config.RatePolicy{Rate: 1, Burst: 2}
+	for i := 0; i < policy.Burst; i++ {
+		allowMemory(t, l, "a", policy)
+	}
+	clock.Add(999 * time.Millisecond)
+	if result := allowMemory(t, l, "a", policy); result.Allowed {
+		t.Fatal("request before refill should be denied")
+	}
+	clock.Add(time.Millisecond)
+	if result := allowMemory(t, l, "a", policy); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("request after refill = %+v, want allowed", result)
+	}
+	// 长时间空闲后最多恢复到 Burst
+	clock.Add(10 * time.Second)
+	for i := 0; i < policy.Burst; i++ {
+		if result := allowMemory(t, l, "a", policy); !result.Allowed {
+			t.Fatalf("request %d after idle should be allowed", i)
+		}
+	}
+	if result := allowMemory(t, l, "a", policy); result.Allowed {
+		t.Fatal("tokens should not exceed burst")
+	}
+	// 策略变化后按新的策略重新计算
+	if result := allowMemory(t, l, "a", config.RatePolicy{Rate: 1, Burst: 5}); !result.Allowed || result.Remaining != 4 {
+		t.Fatalf("request with new policy = %+v, want allowed with remaining 4", result)
+	}
+}
+
+// TestMemoryLimiterGC 空闲超过 idle 的记录在之后的访问中清理，清理间隔也为 idle
+func TestMemoryLimiterGC(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := newTestMemoryLimiter(time.Minute, clock)
+	policy := config.RatePolicy{Rate: 1, Burst: 1}
+	allowMemory(t, l, "a", policy)
+	clock.Add(30 * time.Second)
+	allowMemory(t, l, "b", policy)
+	clock.Add(30 * time.Second)
+	allowMemory(t, l, "c", policy)
+	if keys := visitorKeys(l); len(keys) != 3 {
+		t.Fatalf("visitors before idle = %v, want 3", keys)
+	}
+	clock.Add(time.Second)
+	allowMemory(t, l, "c", policy)
+	if keys := visitorKeys(l); len(keys) != 2 || keys[0] != "b" {
+		t.Fatalf("visitors after a idle = %v, want [b c]", keys)
+	}
+	// b 已空闲超过 idle，但距离上次清理不足 idle，暂不清理
+	clock.Add(30 * time.Second)
+	allowMemory(t, l, "c", policy)
+	if keys := visitorKeys(l); len(keys) != 2 {
+		t.Fatalf("visitors before next gc = %v, want [b c]", keys)
+	}
+	clock.Add(31 * time.Second)
+	allowMemory(t, l, "c", policy)
+	if keys := visitorKeys(l); len(keys) != 1 || keys[0] != "c" {
+		t.Fatalf("visitors after next gc = %v, want [c]", keys)
+	}
+	if l := NewMemoryLimiter(0); l.idle != defaultLimiterIdle {
+		t.Fatalf("default idle = %v, want %v", l.idle, defaultLimiterIdle)
+	}
+}
@@ -0,0 +1,31 @@
+package util
+
+import "github.com/gin-gonic/gin"
+
+const (
+	AuthSchemeToken = "token"
+	AuthSchemeJWT   = "jwt"
+)
+
+// Principal 认证后的用户信息，存放在 gin.Context 的 UsrKey 中
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	// 认证方式
+	Scheme string `json:"scheme"`
+}
+
+func (p *Principal) String() string {
+	return p.Subject
+}
+
+// GetPrincipal 获取当前请求认证的用户
+func GetPrincipal(ctx *gin.Context) (*Principal, bool) {
+	v, ok := ctx.Get(UsrKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok
+}
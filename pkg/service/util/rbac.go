@@ -0,0 +1,140 @@
+package util
+
+import (
+	"context"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/domain"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// RoleProvider 查询角色的权限，角色不存在时返回空
+type RoleProvider interface {
+	Permissions(ctx context.Context, role string) ([]domain.Permission, error)
+}
+
+// Authorizer 根据用户角色判断对资源的操作权限
+type Authorizer struct {
+	cfg *config.RBACConfig
+	// 配置中没有的角色依次从这里查找
+	providers []RoleProvider
+}
+
+func NewAuthorizer(cfg *config.RBACConfig, providers ...RoleProvider) *Authorizer {
+	return &Authorizer{cfg: cfg, providers: providers}
+}
+
+// Enabled 未配置RBAC时允许所有操作
+func (a *Authorizer) Enabled() bool {
+	return a != nil && a.cfg != nil
+}
+
+// Roles 用户的角色，token认证的内部用户和未认证用户使用配置中的角色
+func (a *Authorizer) Roles(p *Principal) []string {
+	if p == nil {
+		return a.cfg.AnonymousRoles
+	}
+	if p.Scheme == AuthSchemeToken && len(p.Roles) == 0 {
+		return a.cfg.TokenRoles
+	}
+	return p.Roles
+}
+
+// Permissions 用户所有角色的权限合集
+func (a *Authorizer) Permissions(ctx context.Context, p *Principal) ([]domain.Permission, error) {
+	var perms []domain.Permission
+	for _, role := range a.Roles(p) {
+		if rolePerms, ok := a.cfg.Roles[role]; ok {
+			perms = append(perms, rolePerms...)
+			continue
+		}
+		for _, provider := range a.providers {
+			rolePerms, err := provider.Permissions(ctx, role)
+			if err != nil {
+				return nil, err
+			}
+			if len(rolePerms) > 0 {
+				perms = append(perms, rolePerms...)
+				break
+			}
+		}
+	}
+	return perms, nil
+}
+
+// Allowed 权限中是否包含对资源的操作
+func Allowed(perms []domain.Permission, resource, verb string) bool {
+	for _, p := range perms {
+		if p.Resource != config.RBACAll && p.Resource != resource {
+			continue
+		}
+		for _, v := range p.Verbs {
+			if v == config.RBACAll || strings.EqualFold(v, verb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RBAC 校验用户对资源的操作权限，操作为HTTP方法，只检查路由中包含 resources 的请求
+// 需要在认证之后使用
+func RBAC(authorizer *Authorizer, resources map[string]any) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !authorizer.Enabled() {
+			return
+		}
+		resource := resourceOfRoute(ctx.FullPath(), resources)
+		if len(resource) == 0 {
+			return
+		}
+		p, ok := GetPrincipal(ctx)
+		if !ok && len(authorizer.cfg.AnonymousRoles) == 0 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"msg": "authentication required"})
+			return
+		}
+		perms, err := authorizer.Permissions(ctx, p)
+		if err != nil {
+			log.FromContext(ctx).Errorw("rbac load permissions error", "error", err)
+			JSONInternalError(ctx, err)
+			return
+		}
+		if !Allowed(perms, resource, ctx.Request.Method) {
+			log.FromContext(ctx).Warnw("rbac permission denied", "resource", resource, "verb", ctx.Request.Method)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"msg": "permission denied"})
+			return
+		}
+	}
+}
+
+// AdminResource 运维接口 /admin 的资源名，角色需要该资源的权限
+const AdminResource = "admin"
+
+// RequireAdmin 运维接口权限，启用RBAC时需要 AdminResource 的权限，未启用时只允许token认证的内部用户
+// 需要在认证之后使用
+func RequireAdmin(authorizer *Authorizer) gin.HandlerFunc {
+	rbac := RBAC(authorizer, map[string]any{AdminResource: struct{}{}})
+	return func(ctx *gin.Context) {
+		if authorizer.Enabled() {
+			rbac(ctx)
+			return
+		}
+		if p, ok := GetPrincipal(ctx); !ok || p.Scheme != AuthSchemeToken {
+			log.FromContext(ctx).Warnw("admin permission denied")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"msg": "permission denied"})
+			return
+		}
+	}
+}
+
+// resourceOfRoute 取路由中属于资源的一段，例如 /api/v1/template/:id 返回 template
+func resourceOfRoute(route string, resources map[string]any) string {
+	for _, seg := range strings.Split(route, "/") {
+		if _, ok := resources[seg]; ok {
+			return seg
+		}
+	}
+	return ""
+}
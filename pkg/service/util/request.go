@@ -0,0 +1,52 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	RequestIDKey    = "request_id"
+	// LoggerName 请求日志的命名日志，中间件和接口通过 log.FromContext 使用
+	LoggerName = "http"
+	// 上游传入的request id过长时重新生成，避免日志注入
+	maxRequestIDLength = 64
+)
+
+// RequestLogger 分配或透传 X-Request-ID，并将带有请求信息的日志放入 gin.Context 和 context.Context
+// 需要在所有中间件和路由之前注册，认证失败的请求也有 request id，认证用户由 RequestUserLogger 补充
+func RequestLogger() gin.HandlerFunc {
+	named := log.Named(LoggerName)
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+			requestID = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Set(RequestIDKey, requestID)
+
+		logger := named.With(RequestIDKey, requestID, "route", ctx.FullPath())
+		ctx.Request = ctx.Request.WithContext(log.NewContext(ctx.Request.Context(), &logger))
+		ctx.Next()
+	}
+}
+
+// RequestUserLogger 在认证之后使用，请求日志中记录认证用户
+func RequestUserLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if usr, ok := ctx.Get(UsrKey); ok {
+			logger := log.FromContext(ctx.Request.Context()).With(UsrKey, usr)
+			ctx.Request = ctx.Request.WithContext(log.NewContext(ctx.Request.Context(), &logger))
+		}
+		ctx.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignVersionLegacy 旧版签名 md5(timestamp-token)，仅迁移期间兼容
+	SignVersionLegacy = "v1"
+	// SignVersionHMAC HMAC-SHA256签名，覆盖请求方法、路径、参数和body
+	SignVersionHMAC = "v2"
+	signQueryKey    = "sign"
+)
+
+var (
+	ErrSignExpired = errors.New("token sign expired")
+	ErrSignReplay  = errors.New("token sign replayed")
+)
+
+/*
+SignVerifier 校验token签名
+v2 签名方式：hex(HMAC-SHA256(secret, content))，content 由以下各行以 \n 拼接
+ 1. 版本号 v2
+ 2. 请求方法，大写
+ 3. 请求路径
+ 4. 除 sign 外的所有 query 参数，按 key 排序后 url 编码
+ 5. hex(sha256(body))
+
+token、timestamp、nonce、sign_version 都在query参数中，因此也包含在签名内容里
+*/
+type SignVerifier struct {
+	secrets     map[string]string
+	skew        time.Duration
+	allowLegacy bool
+	nonces      *nonceCache
+	now         func() time.Time
+}
+
+func NewSignVerifier(cfg *config.SignConfig) *SignVerifier {
+	if cfg == nil {
+		cfg = config.DefaultSignConfig()
+	}
+	skew := time.Duration(cfg.ClockSkewSeconds) * time.Second
+	return &SignVerifier{
+		secrets:     cfg.Secrets,
+		skew:        skew,
+		allowLegacy: cfg.AllowLegacy,
+		// 超出时间窗口的签名已被拒绝，nonce只需要缓存窗口两倍的时间
+		nonces: newNonceCache(2 * skew),
+		now:    time.Now,
+	}
+}
+
+func (v *SignVerifier) Verify(ctx *gin.Context, token *Token) error {
+	ts, err := v.checkTimestamp(token.Timestamp)
+	if err != nil {
+		return err
+	}
+	switch token.Version {
+	case SignVersionHMAC:
+		return v.verifyHMAC(ctx, token)
+	case SignVersionLegacy, "":
+		if !v.allowLegacy {
+			return fmt.Errorf("token sign version %s not allowed", SignVersionLegacy)
+		}
+		if TokenSign(ts, token.Token) != token.Sign {
+			return fmt.Errorf("token check sign error, token:%s", token.Token)
+		}
+		// 每次接受旧版签名都记录，用于找出尚未迁移到 v2 的客户端，日志中不记录token
+		log.FromContext(ctx.Request.Context()).Warnw("deprecated legacy token sign accepted, migrate to v2",
+			"sign_version", SignVersionLegacy, "ip", ctx.ClientIP(), "user_agent", ctx.Request.UserAgent())
+		return nil
+	default:
+		return fmt.Errorf("unknown token sign version %s", token.Version)
+	}
+}
+
+func (v *SignVerifier) checkTimestamp(timestamp string) (time.Time, error) {
+	second, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("token timestamp error: %w", err)
+	}
+	ts := time.Unix(second, 0)
+	if diff := v.now().Sub(ts); diff > v.skew || diff < -v.skew {
+		return ts, ErrSignExpired
+	}
+	return ts, nil
+}
+
+func (v *SignVerifier) verifyHMAC(ctx *gin.Context, token *Token) error {
+	secret, ok := v.secrets[token.Token]
+	if !ok || len(secret) == 0 {
+		return fmt.Errorf("token %s has no sign secret", token.Token)
+	}
+	if len(token.Nonce) == 0 {
+		return errors.New("token nonce is required")
+	}
+	content, err := SignContent(ctx)
+	if err != nil {
+		return err
+	}
+	expected, err := hex.DecodeString(token.Sign)
+	if err != nil || !hmac.Equal(expected, hmacSHA256(secret, content)) {
+		return fmt.Errorf("token check sign error, token:%s", token.Token)
+	}
+	// 签名正确后再记录nonce，避免伪造请求占用nonce
+	if !v.nonces.add(token.Token+":"+token.Nonce, v.now()) {
+		return ErrSignReplay
+	}
+	return nil
+}
+
+// SignContent v2 待签名内容，读取body后会重新放回请求
+func SignContent(ctx *gin.Context) (string, error) {
+	var body []byte
+	if ctx.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return "", fmt.Errorf("read request body error: %w", err)
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	query := ctx.Request.URL.Query()
+	query.Del(signQueryKey)
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		SignVersionHMAC,
+		strings.ToUpper(ctx.Request.Method),
+		ctx.Request.URL.Path,
+		query.Encode(),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n"), nil
+}
+
+// HMACSign 客户端使用，计算v2签名
+func HMACSign(secret, content string) string {
+	return hex.EncodeToString(hmacSHA256(secret, content))
+}
+
+func hmacSHA256(secret, content string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+// nonceCache 记录时间窗口内使用过的nonce，过期的nonce在写入时清理
+// 只在进程内有效，多副本之间不共享，见 config.SignConfig
+type nonceCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	nonces map[string]time.Time
+	// 上次清理时间，避免每次写入都遍历
+	lastGC time.Time
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, nonces: map[string]time.Time{}}
+}
+
+// add nonce已存在且未过期时返回false
+func (c *nonceCache) add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastGC) > c.ttl {
+		for k, expire := range c.nonces {
+			if now.After(expire) {
+				delete(c.nonces, k)
+			}
+		}
+		c.lastGC = now
+	}
+	if expire, ok := c.nonces[nonce]; ok && !now.After(expire) {
+		return false
+	}
+	c.nonces[nonce] = now.Add(c.ttl)
+	return true
+}
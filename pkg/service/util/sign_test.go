@@ -0,0 +1,125 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const (
+	testSignToken  = "tk"
+	testSignSecret = "secret"
+)
+
+func newTestSignVerifier(now time.Time, allowLegacy bool) *SignVerifier {
+	v := NewSignVerifier(&config.SignConfig{
+		Secrets:          map[string]string{testSignToken: testSignSecret},
+		ClockSkewSeconds: 300,
+		AllowLegacy:      allowLegacy,
+	})
+	v.now = func() time.Time { return now }
+	return v
+}
+
+// signContext 构造请求，token 未设置签名时按 v2 签名，返回请求中记录的日志
+func signContext(token *Token, body string) (*gin.Context, *observer.ObservedLogs) {
+	query := url.Values{}
+	query.Set("token", token.Token)
+	query.Set("timestamp", token.Timestamp)
+	query.Set("nonce", token.Nonce)
+	query.Set("sign_version", token.Version)
+	c := getTestGinContest(http.MethodPost, "/api/v1/role?"+query.Encode(), nil, body)
+	if len(token.Sign) == 0 {
+		bodyHash := sha256.Sum256([]byte(body))
+		content := strings.Join([]string{
+			SignVersionHMAC, http.MethodPost, "/api/v1/role", query.Encode(), hex.EncodeToString(bodyHash[:]),
+		}, "\n")
+		token.Sign = HMACSign(testSignSecret, content)
+	}
+	core, logs := observer.New(zapcore.DebugLevel)
+	c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), log.NewLogger(zap.New(core))))
+	return c, logs
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	tests := []struct {
+		name        string
+		allowLegacy bool
+		token       Token
+		wantErr     error
+		valid       bool
+		warn        bool
+	}{
+		{"legacy", true, Token{Token: testSignToken, Timestamp: timestamp, Sign: TokenSign(now, testSignToken)}, nil, true, true},
+		{"legacy with version", true, Token{Token: testSignToken, Timestamp: timestamp, Sign: TokenSign(now, testSignToken), Version: SignVersionLegacy}, nil, true, true},
+		{"legacy not allowed", false, Token{Token: testSignToken, Timestamp: timestamp, Sign: TokenSign(now, testSignToken)}, nil, false, false},
+		{"legacy wrong sign", true, Token{Token: testSignToken, Timestamp: timestamp, Sign: TokenSign(now.Add(time.Second), testSignToken)}, nil, false, false},
+		{"hmac", true, Token{Token: testSignToken, Timestamp: timestamp, Nonce: "1", Version: SignVersionHMAC}, nil, true, false},
+		{"hmac without legacy", false, Token{Token: testSignToken, Timestamp: timestamp, Nonce: "1", Version: SignVersionHMAC}, nil, true, false},
+		{"hmac wrong sign", true, Token{Token: testSignToken, Timestamp: timestamp, Nonce: "1", Version: SignVersionHMAC, Sign: HMACSign("other", "")}, nil, false, false},
+		{"hmac without nonce", true, Token{Token: testSignToken, Timestamp: timestamp, Version: SignVersionHMAC}, nil, false, false},
+		{"hmac without secret", true, Token{Token: "other", Timestamp: timestamp, Nonce: "1", Version: SignVersionHMAC}, nil, false, false},
+		{"expired", true, Token{Token: testSignToken, Timestamp: strconv.FormatInt(now.Unix()-301, 10), Nonce: "1", Version: SignVersionHMAC}, ErrSignExpired, false, false},
+		{"unknown version", true, Token{Token: testSignToken, Timestamp: timestamp, Nonce: "1", Version: "v3", Sign: "x"}, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestSignVerifier(now, tt.allowLegacy)
+			c, logs := signContext(&tt.token, `{"name":"admin"}`)
+			err := v.Verify(c, &tt.token)
+			if (err == nil) != tt.valid {
+				t.Fatalf("verify error = %v, want valid %v", err, tt.valid)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify error = %v, want %v", err, tt.wantErr)
+			}
+			warns := logs.FilterLevelExact(zapcore.WarnLevel).All()
+			if (len(warns) == 1) != tt.warn || len(warns) > 1 {
+				t.Fatalf("warn logs = %v, want warn %v", warns, tt.warn)
+			}
+			// 日志中不记录token
+			for _, entry := range warns {
+				for k, f := range entry.ContextMap() {
+					if f == testSignToken {
+						t.Fatalf("warn log field %s contains token", k)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestSignReplay 同一 nonce 只能使用一次，不同 nonce 不受影响
+func TestSignReplay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestSignVerifier(now, false)
+	verify := func(nonce string) error {
+		token := Token{Token: testSignToken, Timestamp: strconv.FormatInt(now.Unix(), 10), Nonce: nonce, Version: SignVersionHMAC}
+		c, _ := signContext(&token, "")
+		return v.Verify(c, &token)
+	}
+	if err := verify("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify("1"); !errors.Is(err, ErrSignReplay) {
+		t.Fatalf("replay error = %v, want %v", err, ErrSignReplay)
+	}
+	if err := verify("2"); err != nil {
+		t.Fatal(err)
+	}
+}
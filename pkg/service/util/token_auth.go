@@ -4,9 +4,10 @@ import (
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/util/log"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"strconv"
+	"net/http"
 	"time"
 )
 
@@ -25,14 +26,17 @@ type Token struct {
 	Token     string `form:"token" json:"token"`
 	Timestamp string `form:"timestamp" json:"timestamp"`
 	Sign      string `form:"sign" json:"sign"`
+	// 签名版本，为空时按旧版处理
+	Version string `form:"sign_version" json:"sign_version"`
+	// 防重放，v2签名必填
+	Nonce string `form:"nonce" json:"nonce"`
 }
 
 func (t *Token) Empty() bool {
 	return len(t.Token) == 0 && len(t.Timestamp) == 0 && len(t.Sign) == 0
 }
 
-func TokenAuthentication(auth TokenAuth) gin.HandlerFunc {
-	// todo 日志
+func TokenAuthentication(auth TokenAuth, verifier *SignVerifier) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// 如果不适用token验证，适用别的验证方式
 		if !UseTokenAuthentication(ctx) {
@@ -44,32 +48,21 @@ func TokenAuthentication(auth TokenAuth) gin.HandlerFunc {
 		token, err := getTokenFromCtx(ctx)
 		if err != nil {
 			JSONRequestError(ctx, err)
-		}
-		if !auth.CheckToken(ctx, token.Token) {
-			JSONRequestError(ctx, errors.New("invalid token"))
 			return
 		}
-		if err = checkAuthentication(token); err != nil {
+		// 先校验签名，通过后再设置用户
+		if err = verifier.Verify(ctx, token); err != nil {
+			log.FromContext(ctx).Warnw("token sign verify failed", "token", token.Token, "version", token.Version, "error", err)
 			JSONRequestError(ctx, err)
 			return
 		}
+		if !auth.CheckToken(ctx, token.Token) {
+			JSONRequestError(ctx, errors.New("invalid token"))
+			return
+		}
 	}
 }
 
-func checkAuthentication(token *Token) error {
-	second, err := strconv.ParseInt(token.Timestamp, 10, 64)
-	if err != nil {
-		// todo 日志
-		return err
-	}
-	ts := time.Unix(second, 0)
-	checkSign := TokenSign(ts, token.Token)
-	if checkSign != token.Sign {
-		return fmt.Errorf("token check sign error, token:%s", token)
-	}
-	return nil
-}
-
 func getTokenFromCtx(ctx *gin.Context) (*Token, error) {
 	token := &Token{}
 	if err := ctx.ShouldBindWith(token, binding.Query); err != nil {
@@ -106,7 +99,7 @@ func (a *realTokenAuth) CheckToken(ctx *gin.Context, token string) bool {
 	if _, has := a.Tokens[token]; !has {
 		return false
 	}
-	ctx.Set(UsrKey, InternalDeveloper)
+	ctx.Set(UsrKey, &Principal{Subject: InternalDeveloper, Name: InternalDeveloper, Scheme: AuthSchemeToken})
 	return true
 }
 
@@ -115,7 +108,25 @@ func UseTokenAuthentication(ctx *gin.Context) bool {
 	return err == nil && !token.Empty()
 }
 
+// TokenSign 旧版签名，仅用于兼容，新接入请使用 HMACSign
 func TokenSign(now time.Time, token string) string {
 	vin := fmt.Sprintf("%s-%s", now.Format(TimeLayout), token)
 	return fmt.Sprintf("%s", md5.Sum([]byte(vin)))
 }
+
+// RequireUser 必须通过认证，用于管理接口等不允许匿名访问的路由，whiteList 中的路由允许匿名访问
+func RequireUser(whiteList ...string) gin.HandlerFunc {
+	allowed := make(map[string]any, len(whiteList))
+	for _, wl := range whiteList {
+		allowed[wl] = struct{}{}
+	}
+	return func(ctx *gin.Context) {
+		if _, ok := allowed[ctx.FullPath()]; ok {
+			return
+		}
+		if _, ok := ctx.Get(UsrKey); !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"msg": "authentication required"})
+			return
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package log
+
+import "context"
+
+type loggerCtxKey struct{}
+
+// NewContext 将请求级别的日志放入context，下游通过 FromContext 获取
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// FromContext 获取context中的日志，不存在时返回默认日志
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	return defaultLogger
+}
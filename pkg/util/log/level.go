@@ -0,0 +1,109 @@
+package log
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+)
+
+// namedLevel 命名日志的级别，未覆盖时跟随全局级别
+type namedLevel struct {
+	global   zap.AtomicLevel
+	override zap.AtomicLevel
+	mu       sync.RWMutex
+	set      bool
+}
+
+func (l *namedLevel) Enabled(lvl zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.set {
+		return l.override.Enabled(lvl)
+	}
+	return l.global.Enabled(lvl)
+}
+
+func (l *namedLevel) setLevel(level *zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level == nil {
+		l.set = false
+		return
+	}
+	l.override.SetLevel(*level)
+	l.set = true
+}
+
+func (l *namedLevel) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.set {
+		return l.override.String()
+	}
+	return ""
+}
+
+// LevelInfo 全局级别以及命名日志的覆盖级别
+type LevelInfo struct {
+	Level string            `json:"level"`
+	Named map[string]string `json:"named,omitempty"`
+}
+
+// Level 当前的日志级别
+func (b *Builder) Level() LevelInfo {
+	info := LevelInfo{Level: b.level.String(), Named: map[string]string{}}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name, l := range b.named {
+		if s := l.String(); len(s) > 0 {
+			info.Named[name] = s
+		}
+	}
+	return info
+}
+
+// SetLevel 运行时修改全局级别，无需重启
+func (b *Builder) SetLevel(level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	b.level.SetLevel(lvl)
+	return nil
+}
+
+// SetNamedLevel 覆盖命名日志的级别，level 为空时恢复跟随全局级别
+func (b *Builder) SetNamedLevel(name, level string) error {
+	b.mu.Lock()
+	l, ok := b.named[name]
+	b.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("named logger %s not exists", name)
+	}
+	if len(level) == 0 {
+		l.setLevel(nil)
+		return nil
+	}
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.setLevel(&lvl)
+	return nil
+}
+
+// Named 命名日志，可单独调整级别，同名日志共享级别
+// 日志名直接加在 zap 的字段中，不计入 With 的字段，切换命名日志时不会重复
+func (b *Builder) Named(name string) *Logger {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if logger, ok := b.loggers[name]; ok {
+		return logger
+	}
+	l := &namedLevel{global: b.level, override: zap.NewAtomicLevel()}
+	b.named[name] = l
+	logger := NewLogger(zap.New(b.newCore(l), b.options()...).With(zap.String("logger", name)))
+	b.loggers[name] = logger
+	return logger
+}
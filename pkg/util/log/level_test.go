@@ -0,0 +1,102 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"go.uber.org/zap/zapcore"
+)
+
+// newTestBuilder json 格式输出到临时文件，全局级别为 info
+func newTestBuilder(t *testing.T) (*Builder, *Logger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	b := NewBuilder(&config.LogConfig{Level: "info", Encoding: "json", OutputPaths: []string{path}})
+	root, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, root, path
+}
+
+func TestSetNamedLevel(t *testing.T) {
+	b, root, _ := newTestBuilder(t)
+	loggers := map[string]*Logger{"root": root, "dao": b.Named("dao"), "http": b.Named("http")}
+	enabled := func(t *testing.T, want map[string]zapcore.Level) {
+		t.Helper()
+		for name, lvl := range want {
+			core := loggers[name].Base().Core()
+			if !core.Enabled(lvl) || (lvl > zapcore.DebugLevel && core.Enabled(lvl-1)) {
+				t.Fatalf("%s logger level should be %s", name, lvl)
+			}
+		}
+	}
+	steps := []struct {
+		name  string
+		set   func() error
+		level map[string]zapcore.Level
+	}{
+		{"default", func() error { return nil },
+			map[string]zapcore.Level{"root": zapcore.InfoLevel, "dao": zapcore.InfoLevel, "http": zapcore.InfoLevel}},
+		{"named dao", func() error { return b.SetNamedLevel("dao", "error") },
+			map[string]zapcore.Level{"root": zapcore.InfoLevel, "dao": zapcore.ErrorLevel, "http": zapcore.InfoLevel}},
+		{"named http", func() error { return b.SetNamedLevel("http", "debug") },
+			map[string]zapcore.Level{"root": zapcore.InfoLevel, "dao": zapcore.ErrorLevel, "http": zapcore.DebugLevel}},
+		// 覆盖的级别不跟随全局级别
+		{"global", func() error { return b.SetLevel("warn") },
+			map[string]zapcore.Level{"root": zapcore.WarnLevel, "dao": zapcore.ErrorLevel, "http": zapcore.DebugLevel}},
+		{"reset dao", func() error { return b.SetNamedLevel("dao", "") },
+			map[string]zapcore.Level{"root": zapcore.WarnLevel, "dao": zapcore.WarnLevel, "http": zapcore.DebugLevel}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.set(); err != nil {
+				t.Fatal(err)
+			}
+			enabled(t, step.level)
+		})
+	}
+	// 同名日志共享级别
+	if b.Named("http") != loggers["http"] {
+		t.Fatal("named logger should be reused")
+	}
+	if info := b.Level(); info.Level != "warn" || len(info.Named) != 1 || info.Named["http"] != "debug" {
+		t.Fatalf("level = %+v, want warn with http debug", info)
+	}
+	if err := b.SetNamedLevel("job", "info"); err == nil {
+		t.Fatal("set level of unknown named logger should fail")
+	}
+}
+
+// TestLoggerNamed 切换命名日志时保留请求字段，日志名不重复
+func TestLoggerNamed(t *testing.T) {
+	b, _, path := newTestBuilder(t)
+	old := logBuilder
+	logBuilder = b
+	t.Cleanup(func() { logBuilder = old })
+
+	request := b.Named("http").With("request_id", "r1")
+	dao := request.Named("dao")
+	dao.Infow("query")
+	if err := dao.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte(`"logger"`)); n != 1 {
+		t.Fatalf("logger field count = %d, want 1: %s", n, data)
+	}
+	entry := map[string]any{}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["logger"] != "dao" || entry["request_id"] != "r1" {
+		t.Fatalf("entry = %v, want logger dao with request_id r1", entry)
+	}
+}
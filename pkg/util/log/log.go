@@ -1,5 +1,16 @@
 package log
 
+import (
+	"fmt"
+	"github.com/MoWan-inc/aqua/pkg/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"os"
+	"sync"
+	"time"
+)
+
 var (
 	defaultLogger *Logger
 	logBuilder    *Builder
@@ -22,15 +33,178 @@ var (
 	Warnw         = defaultLogger.Warnw
 )
 
-// todo 实现，只需要基本的default实现即可，控制打印到某个默认文件
+// Builder 根据日志配置构建 Logger，级别可在运行时修改
 type Builder struct {
+	cfg     *config.LogConfig
+	level   zap.AtomicLevel
+	encoder zapcore.Encoder
+	writer  zapcore.WriteSyncer
+
+	mu      sync.Mutex
+	named   map[string]*namedLevel
+	loggers map[string]*Logger
+}
+
+func NewBuilder(cfg *config.LogConfig) *Builder {
+	return &Builder{
+		cfg:     cfg,
+		level:   zap.NewAtomicLevel(),
+		named:   map[string]*namedLevel{},
+		loggers: map[string]*Logger{},
+	}
+}
+
+func (b *Builder) Build() (*Logger, error) {
+	if err := b.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := b.SetLevel(b.cfg.Level); err != nil {
+		return nil, err
+	}
+	writer, err := b.buildWriter()
+	if err != nil {
+		return nil, err
+	}
+	b.writer = writer
+	b.encoder = b.buildEncoder()
+	return NewLogger(zap.New(b.newCore(b.level), b.options()...)), nil
+}
+
+func (b *Builder) newCore(enabler zapcore.LevelEnabler) zapcore.Core {
+	core := zapcore.NewCore(b.encoder, b.writer, enabler)
+	if s := b.cfg.Sampling; s != nil {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, s.Initial, s.Thereafter)
+	}
+	return core
+}
+
+func (b *Builder) options() []zap.Option {
+	// Logger 封装了一层调用，需要跳过
+	opts := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(1)}
+	if b.cfg.Development {
+		opts = append(opts, zap.Development(), zap.AddStacktrace(zap.WarnLevel))
+	} else {
+		opts = append(opts, zap.AddStacktrace(zap.ErrorLevel))
+	}
+	return opts
+}
+
+func (b *Builder) buildEncoder() zapcore.Encoder {
+	var encoderCfg zapcore.EncoderConfig
+	if b.cfg.Development {
+		encoderCfg = zap.NewDevelopmentEncoderConfig()
+	} else {
+		encoderCfg = zap.NewProductionEncoderConfig()
+	}
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	if b.cfg.Encoding == "json" {
+		return zapcore.NewJSONEncoder(encoderCfg)
+	}
+	return zapcore.NewConsoleEncoder(encoderCfg)
+}
+
+func (b *Builder) buildWriter() (zapcore.WriteSyncer, error) {
+	writers := make([]zapcore.WriteSyncer, 0, len(b.cfg.OutputPaths))
+	for _, path := range b.cfg.OutputPaths {
+		switch path {
+		case config.LogOutputStdout:
+			writers = append(writers, zapcore.Lock(os.Stdout))
+		case config.LogOutputStderr:
+			writers = append(writers, zapcore.Lock(os.Stderr))
+		default:
+			w, err := b.buildFileWriter(path)
+			if err != nil {
+				return nil, err
+			}
+			writers = append(writers, w)
+		}
+	}
+	return zapcore.NewMultiWriteSyncer(writers...), nil
+}
+
+func (b *Builder) buildFileWriter(path string) (zapcore.WriteSyncer, error) {
+	if r := b.cfg.Rotation; r != nil {
+		return zapcore.AddSync(&lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    r.MaxSizeMB,
+			MaxAge:     r.MaxAgeDays,
+			MaxBackups: r.MaxBackups,
+			LocalTime:  true,
+			Compress:   r.Compress,
+		}), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open log file %s error: %w", path, err)
+	}
+	return zapcore.Lock(f), nil
 }
 
 // 初始化default log
 func init() {
+	builder := NewBuilder(config.DefaultLogConfig())
+	logger, err := builder.Build()
+	if err != nil {
+		logger = NewLogger(zap.NewNop())
+	}
+	logBuilder = builder
+	setDefaultLogger(logger)
+}
+
+// Init 根据配置文件初始化默认日志，路径为空时使用debug模式
+func Init(path string) error {
+	cfg := config.DefaultLogConfig()
+	if len(path) > 0 {
+		if err := cfg.Set(path); err != nil {
+			return fmt.Errorf("load log config %s error: %w", path, err)
+		}
+	}
+	builder := NewBuilder(cfg)
+	logger, err := builder.Build()
+	if err != nil {
+		return fmt.Errorf("build logger error: %w", err)
+	}
+	logBuilder = builder
+	setDefaultLogger(logger)
+	return nil
+}
 
+func setDefaultLogger(l *Logger) {
+	defaultLogger = l
+	With = l.With
+	Debug = l.Debug
+	Info = l.Info
+	Warn = l.Warn
+	Error = l.Error
+	Fatal = l.Fatal
+	Debugf = l.Debugf
+	Fatalf = l.Fatalf
+	Infof = l.Infof
+	Warnf = l.Warnf
+	Errorf = l.Errorf
+	Panic = l.Panic
+	Debugw = l.Debugw
+	Infow = l.Infow
+	ErrorW = l.Errorw
+	Panicf = l.Panicf
+	Warnw = l.Warnw
 }
 
 func GetDefaultLogger() *Logger {
 	return defaultLogger
 }
+
+// GetBuilder 默认日志的 Builder，用于运行时调整级别
+func GetBuilder() *Builder {
+	return logBuilder
+}
+
+// Named 基于默认日志配置创建命名日志
+func Named(name string) *Logger {
+	return logBuilder.Named(name)
+}
+
+// Sync 退出前刷新缓存的日志
+func Sync() error {
+	return defaultLogger.Sync()
+}
@@ -5,6 +5,8 @@ import "go.uber.org/zap"
 type Logger struct {
 	base  *zap.Logger
 	sugar *zap.SugaredLogger
+	// With 添加的字段，切换为命名日志时保留
+	fields []interface{}
 }
 
 func NewLogger(base *zap.Logger) *Logger {
@@ -18,15 +20,34 @@ func (l *Logger) Base() *zap.Logger {
 	return l.base
 }
 
+func (l *Logger) Sync() error {
+	if l.base == nil {
+		return nil
+	}
+	return l.base.Sync()
+}
+
 func (l *Logger) With(args ...interface{}) Logger {
 	if l.sugar == nil {
 		return Logger{}
 	}
 	sl := l.sugar.With(args...)
+	fields := make([]interface{}, 0, len(l.fields)+len(args))
 	return Logger{
-		base:  sl.Desugar(),
-		sugar: sl,
+		base:   sl.Desugar(),
+		sugar:  sl,
+		fields: append(append(fields, l.fields...), args...),
+	}
+}
+
+// Named 切换为默认日志配置的命名日志，保留 With 添加的字段，例如请求日志中的 request id
+func (l *Logger) Named(name string) *Logger {
+	named := Named(name)
+	if len(l.fields) == 0 {
+		return named
 	}
+	w := named.With(l.fields...)
+	return &w
 }
 
 func (l *Logger) Debugw(msg string, args ...interface{}) {